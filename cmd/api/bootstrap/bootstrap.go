@@ -4,6 +4,9 @@ import (
 	"context"
 	"github.com/alexperezortuno/go-url-shortner/internal/config"
 	"github.com/alexperezortuno/go-url-shortner/internal/platform/server"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"io"
+	"log"
 )
 
 func Run() error {
@@ -11,7 +14,38 @@ func Run() error {
 	cfg := config.LoadConfig()
 	ctx := context.Background()
 
+	// Initialize storage
+	links, err := store.New(cfg)
+	if err != nil {
+		return err
+	}
+	if closer, ok := links.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				log.Printf("failed to close storage: %v", err)
+			}
+		}()
+	}
+
 	// Start server
-	c, srv := server.New(ctx, cfg)
+	c, srv := server.New(ctx, cfg, links)
 	return srv.Run(c)
 }
+
+// Migrate applies the pending SQL schema migrations without starting the server
+func Migrate() error {
+	cfg := config.LoadConfig()
+
+	db, err := store.NewSQLStore(cfg.SQLDsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	applied, err := db.Migrate(context.Background())
+	if err != nil {
+		return err
+	}
+	log.Printf("Database migrated successfully: %d migrations applied", applied)
+	return nil
+}
@@ -3,9 +3,17 @@ package main
 import (
 	"github.com/alexperezortuno/go-url-shortner/cmd/api/bootstrap"
 	"log"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := bootstrap.Migrate(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if err := bootstrap.Run(); err != nil {
 		log.Fatal(err)
 	}
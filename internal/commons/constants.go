@@ -1,17 +1,42 @@
 package commons
 
-import "time"
+import (
+	"net/http"
+	"time"
+)
 
 const (
 	HealthPath    = "health"
 	UrlPath       = "url"
 	ShortenerPath = "r"
+	UsersPath     = "users"
+	AdminPath     = "admin"
 )
 
 var (
-	AllowMethods     = []string{"GET", "POST"}                             // Métodos permitidos
-	AllowHeaders     = []string{"Origin", "Content-Type", "Authorization"} // Headers permitidos
-	ExposeHeaders    = []string{"Content-Length"}                          // Headers expuestos
-	AllowCredentials = true                                                // Permitir credenciales
-	MaxAge           = 12 * time.Hour                                      // Tiempo de cacheo de preflight
+	AllowMethods     = []string{"GET", "POST", "PATCH", "DELETE"}                                        // Métodos permitidos
+	AllowHeaders     = []string{"Origin", "Content-Type", "Authorization", "X-API-Key", WorkspaceHeader} // Headers permitidos
+	ExposeHeaders    = append([]string{"Content-Length"}, RateLimitHeaders...)                           // Headers expuestos
+	AllowCredentials = true                                                                              // Permitir credenciales
+	MaxAge           = 12 * time.Hour                                                                    // Tiempo de cacheo de preflight
 )
+
+// RateLimitHeaders are the headers telling the clients of the rate limited routes how many requests they have left
+var RateLimitHeaders = []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+
+// WorkspaceHeader names the workspace of the requests that are not bound to one by their credentials
+const WorkspaceHeader = "X-Workspace"
+
+// AgentContextKey holds the useragent.Agent of the client following a short link in the gin context
+const AgentContextKey = "agent"
+
+// RedirectStatuses are the status codes a short link can redirect with
+var RedirectStatuses = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+// ReservedAliases can not be used as custom aliases because they collide with the API paths
+var ReservedAliases = []string{HealthPath, UrlPath, ShortenerPath, UsersPath, AdminPath}
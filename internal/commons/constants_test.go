@@ -10,6 +10,8 @@ import (
 func TestAllowMethodsContainsExpectedMethods(t *testing.T) {
 	assert.Contains(t, AllowMethods, "GET")
 	assert.Contains(t, AllowMethods, "POST")
+	assert.Contains(t, AllowMethods, "PATCH")
+	assert.Contains(t, AllowMethods, "DELETE")
 	assert.NotContains(t, AllowMethods, "PUT")
 }
 
@@ -17,11 +19,15 @@ func TestAllowHeadersContainsExpectedHeaders(t *testing.T) {
 	assert.Contains(t, AllowHeaders, "Origin")
 	assert.Contains(t, AllowHeaders, "Content-Type")
 	assert.Contains(t, AllowHeaders, "Authorization")
+	assert.Contains(t, AllowHeaders, "X-API-Key")
+	assert.Contains(t, AllowHeaders, "X-Workspace")
 	assert.NotContains(t, AllowHeaders, "X-Custom-Header")
 }
 
 func TestExposeHeadersContainsExpectedHeaders(t *testing.T) {
 	assert.Contains(t, ExposeHeaders, "Content-Length")
+	assert.Contains(t, ExposeHeaders, "Retry-After")
+	assert.Contains(t, ExposeHeaders, "X-RateLimit-Remaining")
 	assert.NotContains(t, ExposeHeaders, "X-Expose-Header")
 }
 
@@ -32,3 +38,16 @@ func TestAllowCredentialsIsTrue(t *testing.T) {
 func TestMaxAgeIs12Hours(t *testing.T) {
 	assert.Equal(t, 12*time.Hour, MaxAge)
 }
+
+func TestRedirectStatusesContainsExpectedCodes(t *testing.T) {
+	assert.ElementsMatch(t, []int{301, 302, 307, 308}, RedirectStatuses)
+	assert.NotContains(t, RedirectStatuses, 303)
+}
+
+func TestReservedAliasesContainsAPIPaths(t *testing.T) {
+	assert.Contains(t, ReservedAliases, "health")
+	assert.Contains(t, ReservedAliases, "url")
+	assert.Contains(t, ReservedAliases, "r")
+	assert.Contains(t, ReservedAliases, "users")
+	assert.Contains(t, ReservedAliases, "admin")
+}
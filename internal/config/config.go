@@ -3,7 +3,6 @@ package config
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"log"
 	"os"
 	"strconv"
 	"strings"
@@ -11,20 +10,52 @@ import (
 )
 
 type Config struct {
-	Protocol             string
-	Host                 string
-	Port                 int
-	ShutdownTimeout      time.Duration
-	Context              string
-	TimeZone             string
-	RedisHost            string
-	RedisPass            string
-	RedisDb              int
-	Release              string
-	CorsAllowsOrigin     []string
-	OtelExporterEndpoint string
-	ServiceName          string
-	TracingEnabled       bool
+	Protocol               string
+	Host                   string
+	Port                   int
+	ShutdownTimeout        time.Duration
+	Context                string
+	TimeZone               string
+	StorageDriver          string
+	BoltPath               string
+	SQLDsn                 string
+	SQLMigrate             bool
+	RedisHost              string
+	RedisPass              string
+	RedisDb                int
+	Release                string
+	CorsAllowsOrigin       []string
+	OtelExporterEndpoint   string
+	ServiceName            string
+	TracingEnabled         bool
+	NotFoundPage           string
+	RedirectStatus         int
+	CodeGenerator          string
+	CodeAlphabet           string
+	CodeLength             int
+	LinkTTL                time.Duration
+	AnalyticsEnabled       bool
+	AnalyticsBuffer        int
+	AnalyticsFlushInterval time.Duration
+	AnalyticsSalt          string
+	AnalyticsSaltRotation  time.Duration
+	AnalyticsClickLog      bool
+	GeoIPDatabase          string
+	AuthEnabled            bool
+	AuthAdminKey           string
+	JWTKeySet              string
+	JWTIssuer              string
+	JWTAudience            string
+	JWTOwnerClaim          string
+	JWTRolesClaim          string
+	JWTWorkspaceClaim      string
+	RateLimitEnabled       bool
+	RateLimitDriver        string
+	CreateRateLimit        int
+	CreateRateWindow       time.Duration
+	RedirectRateLimit      int
+	RedirectRateWindow     time.Duration
+	TrustedProxies         []string
 }
 
 func LoadConfig() *Config {
@@ -41,6 +72,10 @@ func LoadConfig() *Config {
 		Port:                 GetEnvInt("APP_PORT", 8080),
 		TimeZone:             GetEnvStr("APP_TIME_ZONE", "UTC"),
 		ShutdownTimeout:      10 * time.Second,
+		StorageDriver:        GetEnvStr("STORAGE_DRIVER", "redis"),
+		BoltPath:             GetEnvStr("BOLT_PATH", "url-shortener.db"),
+		SQLDsn:               GetEnvStr("SQL_DSN", "file:url-shortener.sqlite?_pragma=busy_timeout(5000)"),
+		SQLMigrate:           GetEnvBool("SQL_MIGRATE", true),
 		RedisHost:            GetEnvStr("REDIS_HOST", "localhost:6379"),
 		RedisPass:            GetEnvStr("REDIS_PASSWORD", ""),
 		RedisDb:              GetEnvInt("REDIS_DB", 0),
@@ -49,10 +84,45 @@ func LoadConfig() *Config {
 		OtelExporterEndpoint: GetEnvStr("OTEL_EXPORTER_OTLP_ENDPOINT", "otel-collector:4317"),
 		ServiceName:          GetEnvStr("SERVICE_NAME", "go-url-shortener"),
 		TracingEnabled:       GetEnvBool("TRACING_ENABLED", false),
+		NotFoundPage:         GetEnvStr("NOT_FOUND_PAGE", ""),
+		RedirectStatus:       GetEnvInt("REDIRECT_STATUS", 308),
+		CodeGenerator:        GetEnvStr("CODE_GENERATOR", "hash"),
+		CodeAlphabet:         GetEnvStr("CODE_ALPHABET", ""),
+		CodeLength:           GetEnvInt("CODE_LENGTH", 8),
+		LinkTTL: func() time.Duration {
+			// links created without expires_at or ttl never expire when the default is "never"
+			if GetEnvStr("LINK_TTL", "") == "never" {
+				return 0
+			}
+			return GetEnvDuration("LINK_TTL", 6*time.Hour)
+		}(),
+		AnalyticsEnabled:       GetEnvBool("ANALYTICS_ENABLED", true),
+		AnalyticsBuffer:        GetEnvInt("ANALYTICS_BUFFER", 4096),
+		AnalyticsFlushInterval: GetEnvDuration("ANALYTICS_FLUSH_INTERVAL", time.Second),
+		AnalyticsSalt:          GetEnvStr("ANALYTICS_SALT", ""),
+		AnalyticsSaltRotation:  GetEnvDuration("ANALYTICS_SALT_ROTATION", 24*time.Hour),
+		AnalyticsClickLog:      GetEnvBool("ANALYTICS_CLICK_LOG", true),
+		GeoIPDatabase:          GetEnvStr("GEOIP_DATABASE", ""),
+		AuthEnabled:            GetEnvBool("AUTH_ENABLED", false),
+		AuthAdminKey:           GetEnvStr("AUTH_ADMIN_KEY", ""),
+		JWTKeySet:              GetEnvStr("JWT_JWKS", ""),
+		JWTIssuer:              GetEnvStr("JWT_ISSUER", ""),
+		JWTAudience:            GetEnvStr("JWT_AUDIENCE", ""),
+		JWTOwnerClaim:          GetEnvStr("JWT_OWNER_CLAIM", "sub"),
+		JWTRolesClaim:          GetEnvStr("JWT_ROLES_CLAIM", "roles"),
+		JWTWorkspaceClaim:      GetEnvStr("JWT_WORKSPACE_CLAIM", "workspace"),
+		RateLimitEnabled:       GetEnvBool("RATE_LIMIT_ENABLED", false),
+		RateLimitDriver:        GetEnvStr("RATE_LIMIT_DRIVER", ""),
+		CreateRateLimit:        GetEnvInt("RATE_LIMIT_CREATE", 60),
+		CreateRateWindow:       GetEnvDuration("RATE_LIMIT_CREATE_WINDOW", time.Minute),
+		RedirectRateLimit:      GetEnvInt("RATE_LIMIT_REDIRECT", 600),
+		RedirectRateWindow:     GetEnvDuration("RATE_LIMIT_REDIRECT_WINDOW", time.Minute),
+		TrustedProxies:         GetEnvStrArray("TRUSTED_PROXIES", nil),
 	}
 }
 
-func (c *Config) SetGinMode() {
+// SetGinMode sets the gin mode matching the release, it returns an error when the release is unknown
+func (c *Config) SetGinMode() error {
 	switch c.Release {
 	case "dev":
 		gin.SetMode(gin.DebugMode)
@@ -61,8 +131,9 @@ func (c *Config) SetGinMode() {
 	case "prod":
 		gin.SetMode(gin.ReleaseMode)
 	default:
-		log.Fatalf("Invalid environment: %s", c.Release)
+		return fmt.Errorf("invalid environment: %s", c.Release)
 	}
+	return nil
 }
 
 func GetEnvStr(key, fallback string) string {
@@ -90,6 +161,15 @@ func GetEnvBool(key string, fallback bool) bool {
 	return fallback
 }
 
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return fallback
+}
+
 func GetEnvStrArray(key string, fallback []string) []string {
 	if value, ok := os.LookupEnv(key); ok {
 		return splitString(value)
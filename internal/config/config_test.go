@@ -7,9 +7,10 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func LoadConfigReturnsDefaultValuesWhenEnvVarsAreNotSet(t *testing.T) {
+func TestLoadConfigReturnsDefaultValuesWhenEnvVarsAreNotSet(t *testing.T) {
 	config := LoadConfig()
 
 	assert.Equal(t, "http", config.Protocol)
@@ -18,6 +19,10 @@ func LoadConfigReturnsDefaultValuesWhenEnvVarsAreNotSet(t *testing.T) {
 	assert.Equal(t, 8080, config.Port)
 	assert.Equal(t, "UTC", config.TimeZone)
 	assert.Equal(t, 10*time.Second, config.ShutdownTimeout)
+	assert.Equal(t, "redis", config.StorageDriver)
+	assert.Equal(t, "url-shortener.db", config.BoltPath)
+	assert.Equal(t, "file:url-shortener.sqlite?_pragma=busy_timeout(5000)", config.SQLDsn)
+	assert.True(t, config.SQLMigrate)
 	assert.Equal(t, "localhost:6379", config.RedisHost)
 	assert.Equal(t, "", config.RedisPass)
 	assert.Equal(t, 0, config.RedisDb)
@@ -26,22 +31,82 @@ func LoadConfigReturnsDefaultValuesWhenEnvVarsAreNotSet(t *testing.T) {
 	assert.Equal(t, "otel-collector:4317", config.OtelExporterEndpoint)
 	assert.Equal(t, "go-url-shortener", config.ServiceName)
 	assert.False(t, config.TracingEnabled)
+	assert.Equal(t, "", config.NotFoundPage)
+	assert.Equal(t, 308, config.RedirectStatus)
+	assert.Equal(t, "hash", config.CodeGenerator)
+	assert.Equal(t, "", config.CodeAlphabet)
+	assert.Equal(t, 8, config.CodeLength)
+	assert.Equal(t, 6*time.Hour, config.LinkTTL)
+	assert.True(t, config.AnalyticsEnabled)
+	assert.Equal(t, 4096, config.AnalyticsBuffer)
+	assert.Equal(t, time.Second, config.AnalyticsFlushInterval)
+	assert.Equal(t, "", config.AnalyticsSalt)
+	assert.Equal(t, 24*time.Hour, config.AnalyticsSaltRotation)
+	assert.True(t, config.AnalyticsClickLog)
+	assert.Equal(t, "", config.GeoIPDatabase)
+	assert.False(t, config.AuthEnabled)
+	assert.Equal(t, "", config.AuthAdminKey)
+	assert.Equal(t, "", config.JWTKeySet)
+	assert.Equal(t, "", config.JWTIssuer)
+	assert.Equal(t, "", config.JWTAudience)
+	assert.Equal(t, "sub", config.JWTOwnerClaim)
+	assert.Equal(t, "roles", config.JWTRolesClaim)
+	assert.Equal(t, "workspace", config.JWTWorkspaceClaim)
+	assert.False(t, config.RateLimitEnabled)
+	assert.Equal(t, "", config.RateLimitDriver)
+	assert.Equal(t, 60, config.CreateRateLimit)
+	assert.Equal(t, time.Minute, config.CreateRateWindow)
+	assert.Equal(t, 600, config.RedirectRateLimit)
+	assert.Equal(t, time.Minute, config.RedirectRateWindow)
+	assert.Empty(t, config.TrustedProxies)
 }
 
-func LoadConfigReturnsOverriddenValuesWhenEnvVarsAreSet(t *testing.T) {
-	setEnv("APP_PROTOCOL", "https")
-	setEnv("APP_HOST", "127.0.0.1")
-	setEnv("APP_CONTEXT", "api")
-	setEnv("APP_PORT", "9090")
-	setEnv("APP_TIME_ZONE", "PST")
-	setEnv("REDIS_HOST", "redis:6379")
-	setEnv("REDIS_PASSWORD", "password")
-	setEnv("REDIS_DB", "1")
-	setEnv("RELEASE", "dev")
-	setEnv("CORS_ALLOW_ORIGIN", "http://example.com,http://test.com")
-	setEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "custom-otel:4317")
-	setEnv("SERVICE_NAME", "custom-service")
-	setEnv("TRACING_ENABLED", "true")
+func TestLoadConfigReturnsOverriddenValuesWhenEnvVarsAreSet(t *testing.T) {
+	t.Setenv("APP_PROTOCOL", "https")
+	t.Setenv("APP_HOST", "127.0.0.1")
+	t.Setenv("APP_CONTEXT", "api")
+	t.Setenv("APP_PORT", "9090")
+	t.Setenv("APP_TIME_ZONE", "PST")
+	t.Setenv("STORAGE_DRIVER", "memory")
+	t.Setenv("BOLT_PATH", "/data/links.db")
+	t.Setenv("SQL_DSN", "file:/data/links.sqlite")
+	t.Setenv("SQL_MIGRATE", "false")
+	t.Setenv("REDIS_HOST", "redis:6379")
+	t.Setenv("REDIS_PASSWORD", "password")
+	t.Setenv("REDIS_DB", "1")
+	t.Setenv("RELEASE", "dev")
+	t.Setenv("CORS_ALLOW_ORIGIN", "http://example.com,http://test.com")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "custom-otel:4317")
+	t.Setenv("SERVICE_NAME", "custom-service")
+	t.Setenv("TRACING_ENABLED", "true")
+	t.Setenv("NOT_FOUND_PAGE", "/etc/url-shortener/404.html")
+	t.Setenv("REDIRECT_STATUS", "302")
+	t.Setenv("CODE_GENERATOR", "random")
+	t.Setenv("CODE_ALPHABET", "abcdef")
+	t.Setenv("CODE_LENGTH", "12")
+	t.Setenv("LINK_TTL", "never")
+	t.Setenv("ANALYTICS_ENABLED", "false")
+	t.Setenv("ANALYTICS_BUFFER", "16")
+	t.Setenv("ANALYTICS_FLUSH_INTERVAL", "5s")
+	t.Setenv("ANALYTICS_SALT", "pepper")
+	t.Setenv("ANALYTICS_SALT_ROTATION", "1h")
+	t.Setenv("ANALYTICS_CLICK_LOG", "false")
+	t.Setenv("GEOIP_DATABASE", "/usr/share/GeoIP/GeoLite2-City.mmdb")
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("AUTH_ADMIN_KEY", "bootstrap")
+	t.Setenv("JWT_JWKS", "https://sso.example.com/.well-known/jwks.json")
+	t.Setenv("JWT_ISSUER", "https://sso.example.com")
+	t.Setenv("JWT_AUDIENCE", "url-shortener")
+	t.Setenv("JWT_OWNER_CLAIM", "email")
+	t.Setenv("JWT_ROLES_CLAIM", "realm_access.roles")
+	t.Setenv("JWT_WORKSPACE_CLAIM", "team")
+	t.Setenv("RATE_LIMIT_ENABLED", "true")
+	t.Setenv("RATE_LIMIT_DRIVER", "memory")
+	t.Setenv("RATE_LIMIT_CREATE", "10")
+	t.Setenv("RATE_LIMIT_CREATE_WINDOW", "1h")
+	t.Setenv("RATE_LIMIT_REDIRECT", "100")
+	t.Setenv("RATE_LIMIT_REDIRECT_WINDOW", "10s")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1, 172.16.0.0/12")
 
 	config := LoadConfig()
 
@@ -50,6 +115,10 @@ func LoadConfigReturnsOverriddenValuesWhenEnvVarsAreSet(t *testing.T) {
 	assert.Equal(t, "/api", config.Context)
 	assert.Equal(t, 9090, config.Port)
 	assert.Equal(t, "PST", config.TimeZone)
+	assert.Equal(t, "memory", config.StorageDriver)
+	assert.Equal(t, "/data/links.db", config.BoltPath)
+	assert.Equal(t, "file:/data/links.sqlite", config.SQLDsn)
+	assert.False(t, config.SQLMigrate)
 	assert.Equal(t, "redis:6379", config.RedisHost)
 	assert.Equal(t, "password", config.RedisPass)
 	assert.Equal(t, 1, config.RedisDb)
@@ -58,98 +127,129 @@ func LoadConfigReturnsOverriddenValuesWhenEnvVarsAreSet(t *testing.T) {
 	assert.Equal(t, "custom-otel:4317", config.OtelExporterEndpoint)
 	assert.Equal(t, "custom-service", config.ServiceName)
 	assert.True(t, config.TracingEnabled)
+	assert.Equal(t, "/etc/url-shortener/404.html", config.NotFoundPage)
+	assert.Equal(t, 302, config.RedirectStatus)
+	assert.Equal(t, "random", config.CodeGenerator)
+	assert.Equal(t, "abcdef", config.CodeAlphabet)
+	assert.Equal(t, 12, config.CodeLength)
+	assert.Equal(t, time.Duration(0), config.LinkTTL)
+	assert.False(t, config.AnalyticsEnabled)
+	assert.Equal(t, 16, config.AnalyticsBuffer)
+	assert.Equal(t, 5*time.Second, config.AnalyticsFlushInterval)
+	assert.Equal(t, "pepper", config.AnalyticsSalt)
+	assert.Equal(t, time.Hour, config.AnalyticsSaltRotation)
+	assert.False(t, config.AnalyticsClickLog)
+	assert.Equal(t, "/usr/share/GeoIP/GeoLite2-City.mmdb", config.GeoIPDatabase)
+	assert.True(t, config.AuthEnabled)
+	assert.Equal(t, "bootstrap", config.AuthAdminKey)
+	assert.Equal(t, "https://sso.example.com/.well-known/jwks.json", config.JWTKeySet)
+	assert.Equal(t, "https://sso.example.com", config.JWTIssuer)
+	assert.Equal(t, "url-shortener", config.JWTAudience)
+	assert.Equal(t, "email", config.JWTOwnerClaim)
+	assert.Equal(t, "realm_access.roles", config.JWTRolesClaim)
+	assert.Equal(t, "team", config.JWTWorkspaceClaim)
+	assert.True(t, config.RateLimitEnabled)
+	assert.Equal(t, "memory", config.RateLimitDriver)
+	assert.Equal(t, 10, config.CreateRateLimit)
+	assert.Equal(t, time.Hour, config.CreateRateWindow)
+	assert.Equal(t, 100, config.RedirectRateLimit)
+	assert.Equal(t, 10*time.Second, config.RedirectRateWindow)
+	assert.Equal(t, []string{"10.0.0.1", "172.16.0.0/12"}, config.TrustedProxies)
 }
 
-func SetGinModeSetsCorrectModeBasedOnRelease(t *testing.T) {
+func TestSetGinModeSetsCorrectModeBasedOnRelease(t *testing.T) {
 	config := &Config{}
 
 	config.Release = "dev"
-	config.SetGinMode()
+	require.NoError(t, config.SetGinMode())
 	assert.Equal(t, gin.DebugMode, gin.Mode())
 
 	config.Release = "test"
-	config.SetGinMode()
+	require.NoError(t, config.SetGinMode())
 	assert.Equal(t, gin.TestMode, gin.Mode())
 
 	config.Release = "prod"
-	config.SetGinMode()
+	require.NoError(t, config.SetGinMode())
 	assert.Equal(t, gin.ReleaseMode, gin.Mode())
 }
 
-func SetGinModePanicsForInvalidRelease(t *testing.T) {
+func TestSetGinModeRejectsInvalidRelease(t *testing.T) {
 	config := &Config{Release: "invalid"}
-	assert.Panics(t, func() {
-		config.SetGinMode()
-	})
+	assert.Error(t, config.SetGinMode())
 }
 
-func GetEnvStrReturnsFallbackWhenEnvVarIsNotSet(t *testing.T) {
+func TestGetEnvStrReturnsFallbackWhenEnvVarIsNotSet(t *testing.T) {
 	unsetEnv("UNSET_ENV_VAR")
 	result := GetEnvStr("UNSET_ENV_VAR", "fallback")
 	assert.Equal(t, "fallback", result)
 }
 
-func GetEnvStrReturnsValueWhenEnvVarIsSet(t *testing.T) {
-	setEnv("SET_ENV_VAR", "value")
+func TestGetEnvStrReturnsValueWhenEnvVarIsSet(t *testing.T) {
+	t.Setenv("SET_ENV_VAR", "value")
 	result := GetEnvStr("SET_ENV_VAR", "fallback")
 	assert.Equal(t, "value", result)
 }
 
-func GetEnvIntReturnsFallbackWhenEnvVarIsNotSet(t *testing.T) {
+func TestGetEnvIntReturnsFallbackWhenEnvVarIsNotSet(t *testing.T) {
 	unsetEnv("UNSET_INT_ENV_VAR")
 	result := GetEnvInt("UNSET_INT_ENV_VAR", 42)
 	assert.Equal(t, 42, result)
 }
 
-func GetEnvIntReturnsValueWhenEnvVarIsSet(t *testing.T) {
-	setEnv("SET_INT_ENV_VAR", "100")
+func TestGetEnvIntReturnsValueWhenEnvVarIsSet(t *testing.T) {
+	t.Setenv("SET_INT_ENV_VAR", "100")
 	result := GetEnvInt("SET_INT_ENV_VAR", 42)
 	assert.Equal(t, 100, result)
 }
 
-func GetEnvIntReturnsFallbackForInvalidValue(t *testing.T) {
-	setEnv("INVALID_INT_ENV_VAR", "invalid")
+func TestGetEnvIntReturnsFallbackForInvalidValue(t *testing.T) {
+	t.Setenv("INVALID_INT_ENV_VAR", "invalid")
 	result := GetEnvInt("INVALID_INT_ENV_VAR", 42)
 	assert.Equal(t, 42, result)
 }
 
-func GetEnvBoolReturnsFallbackWhenEnvVarIsNotSet(t *testing.T) {
+func TestGetEnvBoolReturnsFallbackWhenEnvVarIsNotSet(t *testing.T) {
 	unsetEnv("UNSET_BOOL_ENV_VAR")
 	result := GetEnvBool("UNSET_BOOL_ENV_VAR", true)
 	assert.True(t, result)
 }
 
-func GetEnvBoolReturnsValueWhenEnvVarIsSet(t *testing.T) {
-	setEnv("SET_BOOL_ENV_VAR", "false")
+func TestGetEnvBoolReturnsValueWhenEnvVarIsSet(t *testing.T) {
+	t.Setenv("SET_BOOL_ENV_VAR", "false")
 	result := GetEnvBool("SET_BOOL_ENV_VAR", true)
 	assert.False(t, result)
 }
 
-func GetEnvBoolReturnsFallbackForInvalidValue(t *testing.T) {
-	setEnv("INVALID_BOOL_ENV_VAR", "invalid")
+func TestGetEnvBoolReturnsFallbackForInvalidValue(t *testing.T) {
+	t.Setenv("INVALID_BOOL_ENV_VAR", "invalid")
 	result := GetEnvBool("INVALID_BOOL_ENV_VAR", true)
 	assert.True(t, result)
 }
 
-func GetEnvStrArrayReturnsFallbackWhenEnvVarIsNotSet(t *testing.T) {
+func TestGetEnvDurationReturnsValueWhenEnvVarIsSet(t *testing.T) {
+	t.Setenv("SET_DURATION_ENV_VAR", "90m")
+	result := GetEnvDuration("SET_DURATION_ENV_VAR", time.Hour)
+	assert.Equal(t, 90*time.Minute, result)
+}
+
+func TestGetEnvDurationReturnsFallbackForInvalidValue(t *testing.T) {
+	t.Setenv("INVALID_DURATION_ENV_VAR", "invalid")
+	result := GetEnvDuration("INVALID_DURATION_ENV_VAR", time.Hour)
+	assert.Equal(t, time.Hour, result)
+}
+
+func TestGetEnvStrArrayReturnsFallbackWhenEnvVarIsNotSet(t *testing.T) {
 	unsetEnv("UNSET_ARRAY_ENV_VAR")
 	result := GetEnvStrArray("UNSET_ARRAY_ENV_VAR", []string{"default"})
 	assert.Equal(t, []string{"default"}, result)
 }
 
-func GetEnvStrArrayReturnsSplitValuesWhenEnvVarIsSet(t *testing.T) {
-	setEnv("SET_ARRAY_ENV_VAR", "value1, value2, value3")
+func TestGetEnvStrArrayReturnsSplitValuesWhenEnvVarIsSet(t *testing.T) {
+	t.Setenv("SET_ARRAY_ENV_VAR", "value1, value2, value3")
 	result := GetEnvStrArray("SET_ARRAY_ENV_VAR", []string{"default"})
 	assert.Equal(t, []string{"value1", "value2", "value3"}, result)
 }
 
-func setEnv(key, value string) {
-	err := os.Setenv(key, value)
-	if err != nil {
-		panic(err)
-	}
-}
-
 func unsetEnv(key string) {
 	err := os.Unsetenv(key)
 	if err != nil {
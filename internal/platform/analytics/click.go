@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"fmt"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/geoip"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/useragent"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the daily counters are stored as fields named after the dimension and its value, such as "referrer:example.com"
+const (
+	fieldTotal     = "total"
+	prefixHour     = "hour:"
+	prefixReferrer = "referrer:"
+	prefixCountry  = "country:"
+	prefixBrowser  = "browser:"
+	prefixDevice   = "device:"
+	prefixOS       = "os:"
+	prefixClass    = "class:"
+	prefixBot      = "bot:"
+
+	directReferrer = "direct"
+)
+
+// Click is a redirect served for a link, the client IP is only kept as a salted hash
+type Click struct {
+	Code           string    `json:"code"`
+	Time           time.Time `json:"time"`
+	Referrer       string    `json:"referrer"`
+	UserAgent      string    `json:"user_agent"`
+	IPHash         string    `json:"ip_hash"`
+	AcceptLanguage string    `json:"accept_language"`
+	Browser        string    `json:"browser"`
+	OS             string    `json:"os"`
+	Device         string    `json:"device"`
+	// AgentClass is one of the useragent classes, Bot names the automated client
+	AgentClass string `json:"agent_class"`
+	Bot        string `json:"bot,omitempty"`
+	// Country is an ISO 3166-1 alpha-2 code, the location is empty when no GeoIP database is configured
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region,omitempty"`
+	City    string `json:"city,omitempty"`
+	// Visitor is the rotating fingerprint counted by the unique visitors sketches
+	Visitor string `json:"-"`
+	// Workspace is the workspace of the link, the stats are stored in it
+	Workspace string `json:"-"`
+}
+
+// NewClick describes the redirect of code answered to the request, the link belongs to the workspace of its context
+func NewClick(code string, request *http.Request, clientIP string, agent useragent.Agent, location geoip.Location,
+	fingerprinter Fingerprinter) Click {
+	now := time.Now().UTC()
+	return Click{
+		Code:           code,
+		Time:           now,
+		Referrer:       request.Referer(),
+		UserAgent:      request.UserAgent(),
+		IPHash:         fingerprinter.IPHash(clientIP),
+		AcceptLanguage: request.Header.Get("Accept-Language"),
+		Browser:        agent.Browser,
+		OS:             agent.OS,
+		Device:         agent.Device,
+		AgentClass:     agent.Class,
+		Bot:            agent.Bot,
+		Country:        location.Country,
+		Region:         location.Region,
+		City:           location.City,
+		Visitor:        fingerprinter.Visitor(clientIP, request.UserAgent(), now),
+		Workspace:      store.WorkspaceFrom(request.Context()),
+	}
+}
+
+// counters returns the daily counters incremented by the click, the browser, OS and device are only counted for
+// humans and the bots are counted by name instead
+func (c Click) counters() map[string]int64 {
+	counters := map[string]int64{
+		fieldTotal: 1,
+		fmt.Sprintf("%s%02d", prefixHour, c.Time.Hour()): 1,
+		prefixReferrer + referrerHost(c.Referrer):        1,
+		prefixClass + c.AgentClass:                       1,
+	}
+	if c.Country != "" {
+		counters[prefixCountry+c.Country] = 1
+	}
+	if c.isBot() {
+		counters[prefixBot+c.Bot] = 1
+	} else {
+		counters[prefixBrowser+c.Browser] = 1
+		counters[prefixOS+c.OS] = 1
+		counters[prefixDevice+c.Device] = 1
+	}
+	return counters
+}
+
+func (c Click) isBot() bool {
+	return c.AgentClass != useragent.ClassHuman
+}
+
+// referrerHost reduces the referrer to its host, clicks without referrer are counted as direct
+func referrerHost(referrer string) string {
+	parsed, err := url.Parse(referrer)
+	if err != nil || parsed.Hostname() == "" {
+		return directReferrer
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"io"
+	"time"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// ErrInvalidFormat is returned for an export format other than FormatCSV and FormatJSONL
+var ErrInvalidFormat = errors.New("invalid export format")
+
+// exportColumns is the header of the CSV exports
+var exportColumns = []string{"code", "time", "referrer", "user_agent", "ip_hash", "accept_language", "browser", "os",
+	"device", "agent_class", "bot", "country", "region", "city"}
+
+// Export streams the click log of links between two UTC days, both included
+type Export struct {
+	Format string
+	From   time.Time
+	To     time.Time
+}
+
+// NewExport validates the format and the range before anything is written
+func NewExport(format string, from, to time.Time) (*Export, error) {
+	if format != FormatCSV && format != FormatJSONL {
+		return nil, ErrInvalidFormat
+	}
+	if err := store.ValidateRange(from, to); err != nil {
+		return nil, ErrInvalidRange
+	}
+	return &Export{Format: format, From: from, To: to}, nil
+}
+
+// ContentType is the media type of the export
+func (e *Export) ContentType() string {
+	if e.Format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Write copies the clicks of the links to w one link after the other, oldest first. The clicks are read from the
+// store page by page and written as they come, the export is never held in memory
+func (e *Export) Write(ctx context.Context, repo store.ClickLogRepository, codes []string, w io.Writer) error {
+	write := func(click store.ClickRecord) error {
+		if _, err := w.Write(click.Data); err != nil {
+			return err
+		}
+		_, err := w.Write([]byte("\n"))
+		return err
+	}
+
+	var rows *csv.Writer
+	if e.Format == FormatCSV {
+		rows = csv.NewWriter(w)
+		if err := rows.Write(exportColumns); err != nil {
+			return err
+		}
+		write = func(record store.ClickRecord) error {
+			var click Click
+			if err := json.Unmarshal(record.Data, &click); err != nil {
+				return err
+			}
+			return rows.Write([]string{record.Code, record.Time.Format(time.RFC3339Nano), click.Referrer,
+				click.UserAgent, click.IPHash, click.AcceptLanguage, click.Browser, click.OS, click.Device,
+				click.AgentClass, click.Bot, click.Country, click.Region, click.City})
+		}
+	}
+
+	for _, code := range codes {
+		if err := repo.ScanClicks(ctx, code, e.From, e.To, write); err != nil {
+			return err
+		}
+	}
+	if rows != nil {
+		rows.Flush()
+		return rows.Error()
+	}
+	return nil
+}
+
+// Filename names the export file of subject, a link code or a user
+func (e *Export) Filename(subject string) string {
+	return fmt.Sprintf("clicks-%s-%s-%s.%s", subject, e.From.UTC().Format(store.DayLayout),
+		e.To.UTC().Format(store.DayLayout), e.Format)
+}
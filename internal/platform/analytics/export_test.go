@@ -0,0 +1,68 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendTestClicks(t *testing.T, repo store.ClickLogRepository, clicks ...Click) {
+	records := make([]store.ClickRecord, len(clicks))
+	for i, click := range clicks {
+		data, err := json.Marshal(click)
+		require.NoError(t, err)
+		records[i] = store.ClickRecord{Code: click.Code, Time: click.Time, Data: data}
+	}
+	require.NoError(t, repo.AppendClicks(context.Background(), records))
+}
+
+func TestExportWritesCSVAndJSONLines(t *testing.T) {
+	repo := store.NewMemoryStore()
+	day := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	appendTestClicks(t, repo,
+		Click{Code: "abc123", Time: day, Referrer: "https://news.example.com", UserAgent: "Mozilla/5.0, like Gecko",
+			IPHash: "hash", Browser: "Firefox", OS: "Linux", Device: "desktop", AgentClass: "human", Country: "CL"},
+		Click{Code: "xyz789", Time: day.Add(time.Hour), AgentClass: "unfurler", Bot: "Slack"},
+		Click{Code: "abc123", Time: day.AddDate(0, 0, 1), AgentClass: "human"},
+	)
+
+	export, err := NewExport(FormatCSV, day, day)
+	require.NoError(t, err)
+	assert.Equal(t, "text/csv; charset=utf-8", export.ContentType())
+	assert.Equal(t, "clicks-abc123-2025-03-10-2025-03-10.csv", export.Filename("abc123"))
+	var out bytes.Buffer
+	require.NoError(t, export.Write(context.Background(), repo, []string{"abc123", "xyz789"}, &out))
+	assert.Equal(t, strings.Join([]string{
+		"code,time,referrer,user_agent,ip_hash,accept_language,browser,os,device,agent_class,bot,country,region,city",
+		`abc123,2025-03-10T09:00:00Z,https://news.example.com,"Mozilla/5.0, like Gecko",hash,,Firefox,Linux,desktop,human,,CL,,`,
+		"xyz789,2025-03-10T10:00:00Z,,,,,,,,unfurler,Slack,,,",
+	}, "\n")+"\n", out.String())
+
+	export, err = NewExport(FormatJSONL, day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-ndjson", export.ContentType())
+	out.Reset()
+	require.NoError(t, export.Write(context.Background(), repo, []string{"abc123"}, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var click Click
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &click))
+	assert.Equal(t, "abc123", click.Code)
+	assert.True(t, day.AddDate(0, 0, 1).Equal(click.Time))
+}
+
+func TestNewExportRejectsInvalidParameters(t *testing.T) {
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	_, err := NewExport("xml", day, day)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+	_, err = NewExport(FormatCSV, day, day.AddDate(0, 0, -1))
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
@@ -0,0 +1,45 @@
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// Fingerprinter pseudonymizes the clients of the redirects with a secret salt. Visitor fingerprints are keyed with a
+// salt derived for each rotation period, so the same client can not be followed from one period to the next
+type Fingerprinter struct {
+	secret   []byte
+	rotation time.Duration
+}
+
+// NewFingerprinter returns a Fingerprinter rotating the visitor salt every rotation, a day when it is not positive
+func NewFingerprinter(secret []byte, rotation time.Duration) Fingerprinter {
+	if rotation <= 0 {
+		rotation = 24 * time.Hour
+	}
+	return Fingerprinter{secret: secret, rotation: rotation}
+}
+
+// IPHash returns the stable hash of the client IP
+func (f Fingerprinter) IPHash(ip string) string {
+	return sign(f.secret, []byte(ip))
+}
+
+// Visitor returns the fingerprint of the client during the rotation period containing t
+func (f Fingerprinter) Visitor(ip, userAgent string, t time.Time) string {
+	period := make([]byte, 8)
+	binary.BigEndian.PutUint64(period, uint64(t.UnixNano()/int64(f.rotation)))
+	salt := hmac.New(sha256.New, f.secret)
+	salt.Write(period)
+
+	return sign(salt.Sum(nil), []byte(ip+"\x00"+userAgent))
+}
+
+func sign(key, value []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(value)
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,23 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisitorFingerprintRotatesWithThePeriod(t *testing.T) {
+	f := NewFingerprinter([]byte("salt"), time.Hour)
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	visitor := f.Visitor("192.0.2.1", "curl/8.0", start)
+	assert.Equal(t, visitor, f.Visitor("192.0.2.1", "curl/8.0", start.Add(59*time.Minute)))
+	assert.NotEqual(t, visitor, f.Visitor("192.0.2.1", "curl/8.0", start.Add(time.Hour)))
+	assert.NotEqual(t, visitor, f.Visitor("192.0.2.1", "Firefox", start))
+	assert.NotEqual(t, visitor, f.Visitor("192.0.2.2", "curl/8.0", start))
+	assert.NotEqual(t, visitor, NewFingerprinter([]byte("other"), time.Hour).Visitor("192.0.2.1", "curl/8.0", start))
+
+	// the IP hash does not rotate
+	assert.Equal(t, f.IPHash("192.0.2.1"), NewFingerprinter([]byte("salt"), time.Minute).IPHash("192.0.2.1"))
+}
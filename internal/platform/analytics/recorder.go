@@ -0,0 +1,189 @@
+package analytics
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/alexperezortuno/go-url-shortner/internal/config"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/geoip"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/useragent"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// batchSize is how many clicks are aggregated before the counters are flushed to the store
+	batchSize    = 512
+	flushTimeout = 5 * time.Second
+)
+
+// Recorder aggregates the clicks in the background so the redirects never wait for the store. A nil Recorder
+// records nothing
+type Recorder struct {
+	repo          store.AnalyticsRepository
+	fingerprinter Fingerprinter
+	flushInterval time.Duration
+	clickLog      bool
+	events        chan Click
+	done          chan struct{}
+	mu            sync.RWMutex
+	closed        bool
+	dropped       atomic.Uint64
+}
+
+// counterKey identifies the counters of a link of a workspace for a day
+type counterKey struct {
+	workspace string
+	code      string
+	day       string
+}
+
+// batch aggregates the clicks between two flushes
+type batch struct {
+	counters map[counterKey]map[string]int64
+	visitors map[counterKey]map[string]struct{}
+	// clicks holds the raw clicks appended to the click log by workspace, it stays empty when the log is disabled
+	clicks map[string][]store.ClickRecord
+	size   int
+}
+
+func newBatch() *batch {
+	return &batch{
+		counters: map[counterKey]map[string]int64{},
+		visitors: map[counterKey]map[string]struct{}{},
+		clicks:   map[string][]store.ClickRecord{},
+	}
+}
+
+func (b *batch) add(click Click, clickLog bool) {
+	key := counterKey{workspace: click.Workspace, code: click.Code, day: click.Time.Format(store.DayLayout)}
+	if b.counters[key] == nil {
+		b.counters[key] = map[string]int64{}
+		b.visitors[key] = map[string]struct{}{}
+	}
+	for field, delta := range click.counters() {
+		b.counters[key][field] += delta
+	}
+	// bots are not visitors
+	if !click.isBot() {
+		b.visitors[key][click.Visitor] = struct{}{}
+	}
+	if clickLog {
+		if data, err := json.Marshal(click); err == nil {
+			b.clicks[click.Workspace] = append(b.clicks[click.Workspace],
+				store.ClickRecord{Code: click.Code, Time: click.Time, Data: data})
+		}
+	}
+	b.size++
+}
+
+// NewRecorder starts the background worker, Close must be called to flush the pending clicks
+func NewRecorder(cfg *config.Config, repo store.AnalyticsRepository) *Recorder {
+	salt := []byte(cfg.AnalyticsSalt)
+	if len(salt) == 0 {
+		log.Printf("ANALYTICS_SALT is not set, client IP hashes will change on every restart")
+		salt = make([]byte, 32)
+		_, _ = rand.Read(salt)
+	}
+
+	r := &Recorder{
+		repo:          repo,
+		fingerprinter: NewFingerprinter(salt, cfg.AnalyticsSaltRotation),
+		flushInterval: cfg.AnalyticsFlushInterval,
+		clickLog:      cfg.AnalyticsClickLog,
+		events:        make(chan Click, cfg.AnalyticsBuffer),
+		done:          make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record queues the click of code, it is dropped when the buffer is full
+func (r *Recorder) Record(code string, request *http.Request, clientIP string, agent useragent.Agent,
+	location geoip.Location) {
+	if r == nil {
+		return
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.events <- NewClick(code, request, clientIP, agent, location, r.fingerprinter):
+	default:
+		if dropped := r.dropped.Add(1); dropped%1000 == 1 {
+			log.Printf("Analytics buffer is full, %d clicks dropped so far", dropped)
+		}
+	}
+}
+
+// Close stops accepting clicks and waits until the queued ones are stored
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+	<-r.done
+	return nil
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	pending := newBatch()
+	for {
+		select {
+		case click, ok := <-r.events:
+			if !ok {
+				r.flush(pending)
+				return
+			}
+			if pending.add(click, r.clickLog); pending.size >= batchSize {
+				r.flush(pending)
+				pending = newBatch()
+			}
+		case <-ticker.C:
+			if pending.size > 0 {
+				r.flush(pending)
+				pending = newBatch()
+			}
+		}
+	}
+}
+
+func (r *Recorder) flush(pending *batch) {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	for key, deltas := range pending.counters {
+		ctx := store.WithWorkspace(ctx, key.workspace)
+		day, _ := time.Parse(store.DayLayout, key.day)
+		if err := r.repo.IncrementCounters(ctx, key.code, day, deltas); err != nil {
+			log.Printf("Failed to store click counters | Error: %v - shortURL: %s\n", err, key.code)
+		}
+		if err := r.repo.AddVisitors(ctx, key.code, day, slices.Collect(maps.Keys(pending.visitors[key]))); err != nil {
+			log.Printf("Failed to store visitors | Error: %v - shortURL: %s\n", err, key.code)
+		}
+	}
+	for workspace, clicks := range pending.clicks {
+		if err := r.repo.AppendClicks(store.WithWorkspace(ctx, workspace), clicks); err != nil {
+			log.Printf("Failed to store the click log | Error: %v - clicks: %d\n", err, len(clicks))
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/config"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/geoip"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/useragent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var firefox = useragent.Parse("Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0")
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		AnalyticsBuffer:        1024,
+		AnalyticsFlushInterval: time.Hour,
+		AnalyticsSalt:          "salt",
+		AnalyticsClickLog:      true,
+	}
+}
+
+func TestRecorderAggregatesClicksIntoDailyCounters(t *testing.T) {
+	repo := store.NewMemoryStore()
+	recorder := NewRecorder(newTestConfig(), repo)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recorder.Record("abc123", httptest.NewRequest("GET", "/r/abc123", nil), "192.0.2.1", firefox, geoip.Location{})
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, recorder.Close())
+
+	now := time.Now()
+	counters, err := repo.Counters(context.Background(), "abc123", now, now)
+	require.NoError(t, err)
+	require.Len(t, counters, 1)
+	assert.Equal(t, int64(10), counters[0].Counters["total"])
+	assert.Equal(t, int64(10), counters[0].Counters["referrer:direct"])
+	assert.Equal(t, int64(10), counters[0].Counters["class:human"])
+	assert.Equal(t, int64(10), counters[0].Counters["browser:Firefox"])
+	assert.Equal(t, int64(10), counters[0].Counters["os:Linux"])
+	assert.Equal(t, int64(10), counters[0].Counters["device:desktop"])
+	visitors, err := repo.CountVisitors(context.Background(), "abc123", now, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), visitors)
+	var logged []Click
+	require.NoError(t, repo.ScanClicks(context.Background(), "abc123", now, now, func(record store.ClickRecord) error {
+		var click Click
+		require.NoError(t, json.Unmarshal(record.Data, &click))
+		logged = append(logged, click)
+		return nil
+	}))
+	require.Len(t, logged, 10)
+	assert.Equal(t, "Firefox", logged[0].Browser)
+	assert.NotEmpty(t, logged[0].IPHash)
+	assert.Empty(t, logged[0].Visitor)
+
+	// clicks recorded after Close are ignored
+	recorder.Record("abc123", httptest.NewRequest("GET", "/r/abc123", nil), "192.0.2.1", firefox, geoip.Location{})
+}
+
+func TestRecorderStoresClicksInTheWorkspaceOfTheLink(t *testing.T) {
+	repo := store.NewMemoryStore()
+	recorder := NewRecorder(newTestConfig(), repo)
+
+	eng := store.WithWorkspace(context.Background(), "eng")
+	recorder.Record("abc123", httptest.NewRequest("GET", "/r/eng/abc123", nil).WithContext(eng), "192.0.2.1", firefox,
+		geoip.Location{})
+	recorder.Record("abc123", httptest.NewRequest("GET", "/r/abc123", nil), "192.0.2.1", firefox, geoip.Location{})
+	recorder.Record("abc123", httptest.NewRequest("GET", "/r/abc123", nil), "192.0.2.1", firefox, geoip.Location{})
+	require.NoError(t, recorder.Close())
+
+	now := time.Now()
+	for ctx, total := range map[context.Context]int64{eng: 1, context.Background(): 2} {
+		counters, err := repo.Counters(ctx, "abc123", now, now)
+		require.NoError(t, err)
+		require.Len(t, counters, 1)
+		assert.Equal(t, total, counters[0].Counters["total"])
+		var logged int64
+		require.NoError(t, repo.ScanClicks(ctx, "abc123", now, now, func(store.ClickRecord) error {
+			logged++
+			return nil
+		}))
+		assert.Equal(t, total, logged)
+	}
+}
+
+func TestRecorderCountsBotsByNameWithoutVisitors(t *testing.T) {
+	repo := store.NewMemoryStore()
+	recorder := NewRecorder(newTestConfig(), repo)
+
+	slack := useragent.Parse("Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+	recorder.Record("abc123", httptest.NewRequest("GET", "/r/abc123", nil), "192.0.2.1", slack, geoip.Location{})
+	recorder.Record("abc123", httptest.NewRequest("GET", "/r/abc123", nil), "192.0.2.2", slack, geoip.Location{})
+	require.NoError(t, recorder.Close())
+
+	now := time.Now()
+	counters, err := repo.Counters(context.Background(), "abc123", now, now)
+	require.NoError(t, err)
+	require.Len(t, counters, 1)
+	assert.Equal(t, int64(2), counters[0].Counters["class:unfurler"])
+	assert.Equal(t, int64(2), counters[0].Counters["bot:Slack"])
+	assert.NotContains(t, counters[0].Counters, "class:human")
+	assert.NotContains(t, counters[0].Counters, "browser:Other")
+	visitors, err := repo.CountVisitors(context.Background(), "abc123", now, now)
+	require.NoError(t, err)
+	assert.Zero(t, visitors)
+}
+
+func TestRecorderFlushesPeriodically(t *testing.T) {
+	repo := store.NewMemoryStore()
+	cfg := newTestConfig()
+	cfg.AnalyticsFlushInterval = 10 * time.Millisecond
+	recorder := NewRecorder(cfg, repo)
+	defer recorder.Close()
+
+	recorder.Record("abc123", httptest.NewRequest("GET", "/r/abc123", nil), "192.0.2.1", firefox, geoip.Location{})
+	assert.Eventually(t, func() bool {
+		counters, err := repo.Counters(context.Background(), "abc123", time.Now(), time.Now())
+		return err == nil && len(counters) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNilRecorderRecordsNothing(t *testing.T) {
+	var recorder *Recorder
+	recorder.Record("abc123", httptest.NewRequest("GET", "/r/abc123", nil), "192.0.2.1", firefox, geoip.Location{})
+	assert.NoError(t, recorder.Close())
+}
+
+func TestNewClickHashesTheClientIP(t *testing.T) {
+	request := httptest.NewRequest("GET", "/r/abc123", nil)
+	request.Header.Set("Referer", "https://news.example.com/post")
+	request.Header.Set("User-Agent", "curl/8.0")
+	request.Header.Set("Accept-Language", "es-CL,es;q=0.9")
+	fingerprinter := NewFingerprinter([]byte("salt"), 0)
+
+	click := NewClick("abc123", request, "192.0.2.1", useragent.Parse("curl/8.0"), geoip.Location{Country: "CL"}, fingerprinter)
+	assert.Equal(t, "https://news.example.com/post", click.Referrer)
+	assert.Equal(t, "curl/8.0", click.UserAgent)
+	assert.Equal(t, "es-CL,es;q=0.9", click.AcceptLanguage)
+	assert.Equal(t, useragent.ClassBot, click.AgentClass)
+	assert.Equal(t, "curl", click.Bot)
+	assert.Equal(t, "CL", click.Country)
+	assert.Equal(t, int64(1), click.counters()["country:CL"])
+	assert.NotContains(t, click.IPHash, "192.0.2.1", firefox, geoip.Location{})
+	assert.Equal(t, click.IPHash, NewClick("abc123", request, "192.0.2.1", firefox, geoip.Location{}, fingerprinter).IPHash)
+	assert.NotEqual(t, click.IPHash, NewClick("abc123", request, "192.0.2.1", firefox, geoip.Location{}, NewFingerprinter([]byte("other"), 0)).IPHash)
+}
@@ -0,0 +1,175 @@
+package analytics
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/useragent"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	IntervalDay  = "day"
+	IntervalHour = "hour"
+
+	// TopSize is how many referrers and countries the stats list
+	TopSize = 10
+)
+
+// ErrInvalidRange is returned for a reversed or too long date range and for an unknown interval
+var ErrInvalidRange = errors.New("invalid stats range")
+
+// Bucket is a point of the clicks time series
+type Bucket struct {
+	Time   time.Time `json:"time"`
+	Clicks int64     `json:"clicks"`
+}
+
+// Entry is the clicks of one value of a dimension such as a referrer or a browser
+type Entry struct {
+	Name   string `json:"name"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Stats summarizes the clicks of a link between two UTC days, both included
+type Stats struct {
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	Interval       string   `json:"interval"`
+	TotalClicks    int64    `json:"total_clicks"`
+	HumanClicks    int64    `json:"human_clicks"`
+	BotClicks      int64    `json:"bot_clicks"`
+	UniqueVisitors int64    `json:"unique_visitors"`
+	Series         []Bucket `json:"series"`
+	TopReferrers   []Entry  `json:"top_referrers"`
+	TopCountries   []Entry  `json:"top_countries"`
+	// Browsers, OperatingSystems and Devices only count humans
+	Browsers         []Entry `json:"browsers"`
+	OperatingSystems []Entry `json:"operating_systems"`
+	Devices          []Entry `json:"devices"`
+	// BotClasses and TopBots break the bot clicks down by useragent class and bot name
+	BotClasses []Entry `json:"bot_classes"`
+	TopBots    []Entry `json:"top_bots"`
+}
+
+// LoadStats reads the counters of the link from the store and summarizes them
+func LoadStats(ctx context.Context, repo store.AnalyticsRepository, code string, from, to time.Time,
+	interval string) (*Stats, error) {
+	if interval != IntervalDay && interval != IntervalHour {
+		return nil, ErrInvalidRange
+	}
+
+	days, err := repo.Counters(ctx, code, from, to)
+	if errors.Is(err, store.ErrInvalidQuery) {
+		return nil, ErrInvalidRange
+	}
+	if err != nil {
+		return nil, err
+	}
+	visitors, err := repo.CountVisitors(ctx, code, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := summarize(days, from, to, interval)
+	stats.UniqueVisitors = visitors
+	return stats, nil
+}
+
+func summarize(days []store.DailyCounters, from, to time.Time, interval string) *Stats {
+	first := from.UTC().Truncate(24 * time.Hour)
+	last := to.UTC().Truncate(24 * time.Hour)
+	stats := &Stats{
+		From:     first.Format(store.DayLayout),
+		To:       last.Format(store.DayLayout),
+		Interval: interval,
+	}
+
+	step := 24 * time.Hour
+	if interval == IntervalHour {
+		step = time.Hour
+	}
+	buckets := map[time.Time]int64{}
+	referrers, countries, browsers, devices := map[string]int64{}, map[string]int64{}, map[string]int64{}, map[string]int64{}
+	systems, classes, bots := map[string]int64{}, map[string]int64{}, map[string]int64{}
+	for _, day := range days {
+		start, _ := time.Parse(store.DayLayout, day.Day)
+		stats.TotalClicks += day.Counters[fieldTotal]
+		if interval == IntervalDay {
+			buckets[start] += day.Counters[fieldTotal]
+		}
+
+		for field, value := range day.Counters {
+			name, dimension := dimensionOf(field)
+			switch dimension {
+			case prefixHour:
+				if hour, err := strconv.Atoi(name); err == nil && interval == IntervalHour {
+					buckets[start.Add(time.Duration(hour)*time.Hour)] += value
+				}
+			case prefixReferrer:
+				referrers[name] += value
+			case prefixCountry:
+				countries[name] += value
+			case prefixBrowser:
+				browsers[name] += value
+			case prefixDevice:
+				devices[name] += value
+			case prefixOS:
+				systems[name] += value
+			case prefixBot:
+				bots[name] += value
+			case prefixClass:
+				if name == useragent.ClassHuman {
+					stats.HumanClicks += value
+				} else {
+					stats.BotClicks += value
+					classes[name] += value
+				}
+			}
+		}
+	}
+
+	stats.Series = []Bucket{}
+	for bucket := first; bucket.Before(last.Add(24 * time.Hour)); bucket = bucket.Add(step) {
+		stats.Series = append(stats.Series, Bucket{Time: bucket, Clicks: buckets[bucket]})
+	}
+	stats.TopReferrers = ranking(referrers, TopSize)
+	stats.TopCountries = ranking(countries, TopSize)
+	stats.Browsers = ranking(browsers, 0)
+	stats.OperatingSystems = ranking(systems, 0)
+	stats.Devices = ranking(devices, 0)
+	stats.BotClasses = ranking(classes, 0)
+	stats.TopBots = ranking(bots, TopSize)
+	return stats
+}
+
+// dimensionOf splits a counter field into its value and dimension prefix
+func dimensionOf(field string) (string, string) {
+	prefix, name, ok := strings.Cut(field, ":")
+	if !ok {
+		return field, ""
+	}
+	return name, prefix + ":"
+}
+
+// ranking sorts the entries by clicks, keeping the first limit ones when limit is not zero
+func ranking(values map[string]int64, limit int) []Entry {
+	entries := make([]Entry, 0, len(values))
+	for name, clicks := range values {
+		entries = append(entries, Entry{Name: name, Clicks: clicks})
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if c := cmp.Compare(b.Clicks, a.Clicks); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
@@ -0,0 +1,72 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadStatsSummarizesTheCounters(t *testing.T) {
+	repo := store.NewMemoryStore()
+	ctx := context.Background()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.IncrementCounters(ctx, "abc123", day, map[string]int64{
+		"total": 3, "hour:09": 2, "hour:17": 1, "referrer:news.example.com": 2, "referrer:direct": 1,
+		"country:CL": 3, "browser:Firefox": 2, "os:Linux": 2, "device:desktop": 2, "class:human": 2,
+		"class:unfurler": 1, "bot:Slack": 1,
+	}))
+	require.NoError(t, repo.IncrementCounters(ctx, "abc123", day.AddDate(0, 0, 2), map[string]int64{
+		"total": 1, "hour:00": 1, "referrer:direct": 1, "country:AR": 1, "browser:Safari": 1, "os:iOS": 1,
+		"device:mobile": 1, "class:human": 1,
+	}))
+	require.NoError(t, repo.AddVisitors(ctx, "abc123", day, []string{"a", "b"}))
+	require.NoError(t, repo.AddVisitors(ctx, "abc123", day.AddDate(0, 0, 2), []string{"a"}))
+
+	stats, err := LoadStats(ctx, repo, "abc123", day, day.AddDate(0, 0, 2), IntervalDay)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-03-10", stats.From)
+	assert.Equal(t, "2025-03-12", stats.To)
+	assert.Equal(t, int64(4), stats.TotalClicks)
+	assert.Equal(t, int64(3), stats.HumanClicks)
+	assert.Equal(t, int64(1), stats.BotClicks)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, []Bucket{
+		{Time: day, Clicks: 3},
+		{Time: day.AddDate(0, 0, 1), Clicks: 0},
+		{Time: day.AddDate(0, 0, 2), Clicks: 1},
+	}, stats.Series)
+	assert.Equal(t, []Entry{{Name: "direct", Clicks: 2}, {Name: "news.example.com", Clicks: 2}}, stats.TopReferrers)
+	assert.Equal(t, []Entry{{Name: "CL", Clicks: 3}, {Name: "AR", Clicks: 1}}, stats.TopCountries)
+	assert.Equal(t, []Entry{{Name: "Firefox", Clicks: 2}, {Name: "Safari", Clicks: 1}}, stats.Browsers)
+	assert.Equal(t, []Entry{{Name: "Linux", Clicks: 2}, {Name: "iOS", Clicks: 1}}, stats.OperatingSystems)
+	assert.Equal(t, []Entry{{Name: "desktop", Clicks: 2}, {Name: "mobile", Clicks: 1}}, stats.Devices)
+	assert.Equal(t, []Entry{{Name: "unfurler", Clicks: 1}}, stats.BotClasses)
+	assert.Equal(t, []Entry{{Name: "Slack", Clicks: 1}}, stats.TopBots)
+
+	hourly, err := LoadStats(ctx, repo, "abc123", day, day, IntervalHour)
+	require.NoError(t, err)
+	require.Len(t, hourly.Series, 24)
+	assert.Equal(t, int64(2), hourly.Series[9].Clicks)
+	assert.Equal(t, int64(1), hourly.Series[17].Clicks)
+	assert.Equal(t, int64(3), hourly.TotalClicks)
+}
+
+func TestLoadStatsRejectsInvalidRanges(t *testing.T) {
+	repo := store.NewMemoryStore()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	_, err := LoadStats(context.Background(), repo, "abc123", day, day.AddDate(0, 0, -1), IntervalDay)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = LoadStats(context.Background(), repo, "abc123", day, day, "minute")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestReferrerHost(t *testing.T) {
+	assert.Equal(t, "direct", referrerHost(""))
+	assert.Equal(t, "news.example.com", referrerHost("https://news.example.com/post?id=1"))
+	assert.Equal(t, "example.com", referrerHost("https://WWW.Example.com/"))
+}
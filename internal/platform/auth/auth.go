@@ -0,0 +1,116 @@
+// Package auth describes who is calling the API and what they are allowed to do
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeLinksRead  = "links:read"
+	ScopeLinksWrite = "links:write"
+	ScopeStatsRead  = "stats:read"
+	// ScopeAdmin grants every scope and acting on the links of any user
+	ScopeAdmin = "admin"
+
+	// keyPrefix starts every API key so leaked keys are easy to recognize
+	keyPrefix = "sk_"
+	// principalKey holds the Principal of the request in the gin context
+	principalKey = "principal"
+)
+
+// Scopes are the scopes an API key can be issued with
+var Scopes = []string{ScopeLinksRead, ScopeLinksWrite, ScopeStatsRead, ScopeAdmin}
+
+// ErrMalformedKey is returned by ParseKey for a string that is not an API key
+var ErrMalformedKey = errors.New("malformed API key")
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// UserId owns the links created by the principal, it is empty for the bootstrap admin key
+	UserId string
+	// KeyId identifies the API key used, if any
+	KeyId  string
+	Scopes []string
+	// Workspace is the workspace the principal belongs to, its requests only see the links of that workspace
+	Workspace string
+	// Operator is set for the bootstrap admin key, it belongs to no workspace and picks the one of each request
+	Operator bool
+}
+
+// Admin reports whether the principal has the admin scope
+func (p *Principal) Admin() bool {
+	return slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// HasScope reports whether the principal was granted scope, admins have every scope
+func (p *Principal) HasScope(scope string) bool {
+	return p.Admin() || slices.Contains(p.Scopes, scope)
+}
+
+// SetPrincipal stores the principal of the request
+func SetPrincipal(ctx *gin.Context, principal *Principal) {
+	ctx.Set(principalKey, principal)
+}
+
+// FromContext returns the principal of the request, false when the request is not authenticated
+func FromContext(ctx *gin.Context) (*Principal, bool) {
+	value, ok := ctx.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+// ValidScopes reports whether scopes is a non empty list of known scopes
+func ValidScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewKey generates an API key, the key is shown once to the client and only the id and the hash of the secret are
+// stored
+func NewKey() (id, secret, key string, err error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", "", err
+	}
+	id = hex.EncodeToString(random[:8])
+	secret = base64.RawURLEncoding.EncodeToString(random[8:])
+	return id, secret, keyPrefix + id + "_" + secret, nil
+}
+
+// ParseKey splits an API key into its id and secret
+func ParseKey(key string) (id, secret string, err error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, keyPrefix), "_")
+	if !ok || !strings.HasPrefix(key, keyPrefix) || id == "" || secret == "" {
+		return "", "", ErrMalformedKey
+	}
+	return id, secret, nil
+}
+
+// HashSecret returns the hex encoded SHA-256 of the secret, the secrets are random so no salt or stretching is needed
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchSecret compares the secret with a stored hash in constant time
+func MatchSecret(secret, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(hash)) == 1
+}
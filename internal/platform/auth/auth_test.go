@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyRoundTrips(t *testing.T) {
+	id, secret, key, err := NewKey()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "sk_"))
+
+	parsedId, parsedSecret, err := ParseKey(key)
+	require.NoError(t, err)
+	assert.Equal(t, id, parsedId)
+	assert.Equal(t, secret, parsedSecret)
+	assert.True(t, MatchSecret(secret, HashSecret(secret)))
+	assert.False(t, MatchSecret(secret+"x", HashSecret(secret)))
+
+	_, _, other, err := NewKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestParseKeyRejectsMalformedKeys(t *testing.T) {
+	for _, key := range []string{"", "sk_", "sk_id", "sk_id_", "sk__secret", "pk_id_secret", "id_secret"} {
+		_, _, err := ParseKey(key)
+		assert.ErrorIs(t, err, ErrMalformedKey, key)
+	}
+}
+
+func TestPrincipalScopes(t *testing.T) {
+	reader := &Principal{UserId: "1", Scopes: []string{ScopeLinksRead}}
+	assert.True(t, reader.HasScope(ScopeLinksRead))
+	assert.False(t, reader.HasScope(ScopeLinksWrite))
+	assert.False(t, reader.Admin())
+
+	admin := &Principal{Scopes: []string{ScopeAdmin}}
+	assert.True(t, admin.HasScope(ScopeStatsRead))
+	assert.True(t, admin.Admin())
+
+	assert.True(t, ValidScopes([]string{ScopeLinksRead, ScopeStatsRead}))
+	assert.False(t, ValidScopes(nil))
+	assert.False(t, ValidScopes([]string{"links:delete"}))
+}
+
+func TestPrincipalContext(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	_, ok := FromContext(ctx)
+	assert.False(t, ok)
+
+	SetPrincipal(ctx, &Principal{UserId: "1"})
+	principal, ok := FromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "1", principal.UserId)
+}
+
+func TestGrantsExpandsRoles(t *testing.T) {
+	assert.Equal(t, []string{ScopeLinksRead, ScopeStatsRead}, Grants([]string{RoleViewer}))
+	assert.Equal(t, []string{ScopeLinksRead, ScopeStatsRead, ScopeLinksWrite},
+		Grants([]string{RoleViewer, "sales", RoleEditor, ScopeLinksRead}))
+	assert.Equal(t, []string{ScopeAdmin}, Grants([]string{RoleAdmin}))
+	assert.Empty(t, Grants([]string{"", "owner"}))
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keySetRefreshInterval is how often a remote key set may be fetched again to find a key id it does not know
+	keySetRefreshInterval = time.Minute
+	// keySetMaxAge is how long the keys of a remote key set are used before fetching them again
+	keySetMaxAge = time.Hour
+	// keySetMaxSize bounds the size of the key set document
+	keySetMaxSize = 1 << 20
+)
+
+// ErrUnknownKey is returned by KeySet.Key when no key has the requested id
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet holds the public keys of a JSON Web Key Set (RFC 7517) used to verify the signature of tokens. A key set
+// loaded from a URL is fetched again when a token is signed with an unknown key, so key rotations are picked up
+type KeySet struct {
+	// url is empty for key sets read from a file
+	url    string
+	client *http.Client
+
+	// refreshMu is held by the request fetching the key set, mu is only held to read or swap the keys
+	refreshMu sync.Mutex
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadKeySet reads the key set from source, an http(s) URL or the path of a local file
+func LoadKeySet(ctx context.Context, source string) (*KeySet, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		return ParseKeySet(data)
+	}
+
+	set := &KeySet{url: source, client: &http.Client{Timeout: 10 * time.Second}}
+	if err := set.refresh(ctx, time.Time{}, true); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// ParseKeySet parses a key set document, the keys that are not signing keys or whose type is not supported (RSA, EC
+// P-256/P-384/P-521 and Ed25519 are) are skipped
+func ParseKeySet(data []byte) (*KeySet, error) {
+	keys, err := parseKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	return &KeySet{keys: keys, fetchedAt: time.Now()}, nil
+}
+
+// Key returns the key with id kid, a token without key id can use the only key of a set with one key
+func (s *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.RLock()
+	key, ok := s.lookup(kid)
+	fetchedAt := s.fetchedAt
+	stale := s.url != "" && (!ok && time.Since(fetchedAt) >= keySetRefreshInterval ||
+		time.Since(fetchedAt) >= keySetMaxAge)
+	s.mu.RUnlock()
+	if !stale {
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return key, nil
+	}
+
+	// a request with a known key never waits for another request fetching the key set
+	if err := s.refresh(ctx, fetchedAt, !ok); err != nil {
+		// the keys already known keep working while the key set can not be fetched
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok = s.lookup(kid); !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (s *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the key set unless another request fetched it after seen. While another request fetches it, refresh
+// waits for that fetch when wait is set and returns at once otherwise
+func (s *KeySet) refresh(ctx context.Context, seen time.Time, wait bool) error {
+	if wait {
+		s.refreshMu.Lock()
+	} else if !s.refreshMu.TryLock() {
+		return nil
+	}
+	defer s.refreshMu.Unlock()
+
+	s.mu.Lock()
+	if !s.fetchedAt.Equal(seen) {
+		s.mu.Unlock()
+		return nil
+	}
+	// the time is set before fetching so a failing endpoint is not called on every request
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+// fetch downloads and parses the key set document
+func (s *KeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("fetch key set: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch key set: unexpected status %d", response.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, keySetMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("fetch key set: %w", err)
+	}
+	return parseKeys(data)
+}
+
+func parseKeys(data []byte) (map[string]crypto.PublicKey, error) {
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("parse key set: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("parse key %q: %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("parse key set: no signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key, it returns nil for key types that are not supported
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		// ECDH rejects the points that are not on the curve
+		if _, err := key.ECDH(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
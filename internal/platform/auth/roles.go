@@ -0,0 +1,39 @@
+package auth
+
+import "slices"
+
+const (
+	// RoleViewer reads the links of its user and their stats
+	RoleViewer = "viewer"
+	// RoleEditor also creates, changes and deletes the links of its user
+	RoleEditor = "editor"
+	// RoleAdmin manages the links of every user and the API keys
+	RoleAdmin = "admin"
+)
+
+// Roles maps every role to the scopes it grants
+var Roles = map[string][]string{
+	RoleViewer: {ScopeLinksRead, ScopeStatsRead},
+	RoleEditor: {ScopeLinksRead, ScopeLinksWrite, ScopeStatsRead},
+	RoleAdmin:  {ScopeAdmin},
+}
+
+// Grants returns the scopes granted by names, a mix of roles and scopes. Unknown names are ignored
+func Grants(names []string) []string {
+	var scopes []string
+	grant := func(scope string) {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	for _, name := range names {
+		if roleScopes, ok := Roles[name]; ok {
+			for _, scope := range roleScopes {
+				grant(scope)
+			}
+		} else if slices.Contains(Scopes, name) {
+			grant(name)
+		}
+	}
+	return scopes
+}
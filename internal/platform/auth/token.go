@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tokenLeeway absorbs the clock skew between the identity provider and the service
+const tokenLeeway = time.Minute
+
+// ErrInvalidToken wraps every reason a token is rejected for
+var ErrInvalidToken = errors.New("invalid token")
+
+// tokenMethods are the signing algorithms accepted, only asymmetric ones since the keys come from a public key set
+var tokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// TokenOptions are the claims a token must carry to be trusted and where its owner and roles are read from
+type TokenOptions struct {
+	Issuer   string
+	Audience string
+	// OwnerClaim names the claim holding the user that owns the links, "sub" when empty
+	OwnerClaim string
+	// RolesClaim names the claim holding the roles or scopes, a list or a space separated string. Nested claims are
+	// reached with a dotted path such as "realm_access.roles"
+	RolesClaim string
+	// WorkspaceClaim names the claim holding the workspace of the principal, tokens without it belong to the default
+	// workspace
+	WorkspaceClaim string
+}
+
+// TokenVerifier authenticates the JWTs issued by an identity provider
+type TokenVerifier struct {
+	keys    *KeySet
+	options TokenOptions
+	parser  *jwt.Parser
+}
+
+// NewTokenVerifier returns a verifier of the tokens signed with keys for options.Audience by options.Issuer
+func NewTokenVerifier(keys *KeySet, options TokenOptions) *TokenVerifier {
+	if options.OwnerClaim == "" {
+		options.OwnerClaim = "sub"
+	}
+	return &TokenVerifier{
+		keys:    keys,
+		options: options,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(tokenMethods),
+			jwt.WithIssuer(options.Issuer),
+			jwt.WithAudience(options.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(tokenLeeway),
+		),
+	}
+}
+
+// IsToken reports whether credential has the shape of a JWT, API keys never contain dots
+func IsToken(credential string) bool {
+	return strings.Count(credential, ".") == 2
+}
+
+// Verify checks the signature, issuer, audience and lifetime of the token and returns its principal. The principal is
+// granted the roles and scopes found in its roles claim, the link and stats scopes found in its "scope" claim, and
+// belongs to the workspace of its workspace claim
+func (v *TokenVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	owner, _ := claims[v.options.OwnerClaim].(string)
+	if owner == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.options.OwnerClaim)
+	}
+	workspace := store.DefaultWorkspace
+	if values := claimStrings(claims, v.options.WorkspaceClaim); len(values) > 0 {
+		if workspace = values[0]; len(values) > 1 || !store.ValidWorkspace(workspace) {
+			return nil, fmt.Errorf("%w: invalid %s claim", ErrInvalidToken, v.options.WorkspaceClaim)
+		}
+	}
+	scopes := Grants(claimStrings(claims, v.options.RolesClaim))
+	// only the roles claim is trusted with roles and the admin scope, identity providers issue the scope claim to any
+	// client that asks for the scope names it knows
+	for _, scope := range claimStrings(claims, "scope") {
+		if scope != ScopeAdmin && slices.Contains(Scopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return &Principal{UserId: owner, Scopes: scopes, Workspace: workspace}, nil
+}
+
+// claimStrings returns the strings of the claim at path, it accepts a list of strings or a space separated string
+func claimStrings(claims jwt.MapClaims, path string) []string {
+	if path == "" {
+		return nil
+	}
+	var value interface{} = map[string]interface{}(claims)
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if item, ok := item.(string); ok {
+				values = append(values, item)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "https://sso.example.com"
+	testAudience = "url-shortener"
+)
+
+// testKeySet returns the JSON key set publishing the public keys of signers by key id
+func testKeySet(t *testing.T, signers map[string]crypto.Signer) []byte {
+	encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+	keys := make([]map[string]string, 0, len(signers))
+	for kid, signer := range signers {
+		switch public := signer.Public().(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA", "kid": kid, "use": "sig", "n": encode(public.N), "e": encode(big.NewInt(int64(public.E))),
+			})
+		case *ecdsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "EC", "kid": kid, "crv": "P-256", "x": encode(public.X), "y": encode(public.Y),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+// signTestToken signs claims with key, the issuer, audience and expiration default to valid values
+func signTestToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	defaults := jwt.MapClaims{
+		"iss": testIssuer,
+		"aud": testAudience,
+		"sub": "42",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(defaults, name)
+			continue
+		}
+		defaults[name] = value
+	}
+	token := jwt.NewWithClaims(method, defaults)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func newTestVerifier(t *testing.T, signers map[string]crypto.Signer, options TokenOptions) *TokenVerifier {
+	keys, err := ParseKeySet(testKeySet(t, signers))
+	require.NoError(t, err)
+	options.Issuer, options.Audience = testIssuer, testAudience
+	return NewTokenVerifier(keys, options)
+}
+
+func TestTokenVerifierAcceptsValidTokens(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	verifier := newTestVerifier(t, map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey, "ed": edKey}, TokenOptions{
+		RolesClaim: "roles",
+	})
+
+	for _, token := range []string{
+		signTestToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"roles": []string{"links:read", "sales"}}),
+		signTestToken(t, jwt.SigningMethodES256, "ec", ecKey, jwt.MapClaims{"roles": "links:read sales"}),
+		signTestToken(t, jwt.SigningMethodEdDSA, "ed", edKey, jwt.MapClaims{"scope": "openid links:read"}),
+	} {
+		require.True(t, IsToken(token))
+		principal, err := verifier.Verify(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "42", principal.UserId)
+		assert.Equal(t, []string{ScopeLinksRead}, principal.Scopes)
+		assert.Empty(t, principal.KeyId)
+	}
+}
+
+func TestTokenVerifierMapsConfiguredClaims(t *testing.T) {
+	key := newRSAKey(t)
+	verifier := newTestVerifier(t, map[string]crypto.Signer{"k1": key}, TokenOptions{
+		OwnerClaim: "email",
+		RolesClaim: "realm_access.roles",
+	})
+
+	principal, err := verifier.Verify(context.Background(), signTestToken(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{
+		"email":        "ana@example.com",
+		"realm_access": map[string]interface{}{"roles": []string{"viewer", "offline_access"}},
+		"scope":        "links:write",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "ana@example.com", principal.UserId)
+	assert.Equal(t, []string{ScopeLinksRead, ScopeStatsRead, ScopeLinksWrite}, principal.Scopes)
+}
+
+func TestTokenVerifierDoesNotGrantRolesFromTheScopeClaim(t *testing.T) {
+	key := newRSAKey(t)
+	verifier := newTestVerifier(t, map[string]crypto.Signer{"k1": key}, TokenOptions{RolesClaim: "roles"})
+
+	for _, scope := range []string{"admin", "editor", "openid admin viewer"} {
+		principal, err := verifier.Verify(context.Background(), signTestToken(t, jwt.SigningMethodRS256, "k1", key,
+			jwt.MapClaims{"scope": scope}))
+		require.NoError(t, err)
+		assert.Empty(t, principal.Scopes, scope)
+	}
+
+	principal, err := verifier.Verify(context.Background(), signTestToken(t, jwt.SigningMethodRS256, "k1", key,
+		jwt.MapClaims{"roles": "admin", "scope": "links:read"}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{ScopeAdmin, ScopeLinksRead}, principal.Scopes)
+}
+
+func TestTokenVerifierReadsTheWorkspaceClaim(t *testing.T) {
+	key := newRSAKey(t)
+	verifier := newTestVerifier(t, map[string]crypto.Signer{"k1": key}, TokenOptions{WorkspaceClaim: "workspace"})
+
+	principal, err := verifier.Verify(context.Background(), signTestToken(t, jwt.SigningMethodRS256, "k1", key,
+		jwt.MapClaims{"workspace": "eng"}))
+	require.NoError(t, err)
+	assert.Equal(t, "eng", principal.Workspace)
+	assert.False(t, principal.Operator)
+
+	principal, err = verifier.Verify(context.Background(), signTestToken(t, jwt.SigningMethodRS256, "k1", key, nil))
+	require.NoError(t, err)
+	assert.Empty(t, principal.Workspace)
+
+	for _, workspace := range []interface{}{"Eng", []string{"eng", "sales"}} {
+		_, err = verifier.Verify(context.Background(), signTestToken(t, jwt.SigningMethodRS256, "k1", key,
+			jwt.MapClaims{"workspace": workspace}))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	}
+}
+
+func TestTokenVerifierRejectsInvalidTokens(t *testing.T) {
+	key := newRSAKey(t)
+	other := newRSAKey(t)
+	verifier := newTestVerifier(t, map[string]crypto.Signer{"k1": key}, TokenOptions{})
+
+	tests := map[string]string{
+		"wrong issuer":   signTestToken(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"iss": "https://evil.example.com"}),
+		"wrong audience": signTestToken(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"aud": "other"}),
+		"expired": signTestToken(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{
+			"exp": time.Now().Add(-time.Hour).Unix(),
+		}),
+		"no expiration":  signTestToken(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"exp": nil}),
+		"not yet valid":  signTestToken(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()}),
+		"no owner":       signTestToken(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"sub": nil}),
+		"unknown key id": signTestToken(t, jwt.SigningMethodRS256, "k2", key, nil),
+		"bad signature":  signTestToken(t, jwt.SigningMethodRS256, "k1", other, nil),
+		// a symmetric token signed with the public key must not verify
+		"symmetric": signTestToken(t, jwt.SigningMethodHS256, "k1", []byte("secret"), nil),
+		"garbage":   "a.b.c",
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := verifier.Verify(context.Background(), token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestLoadKeySetFromFile(t *testing.T) {
+	key := newRSAKey(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, testKeySet(t, map[string]crypto.Signer{"k1": key}), 0o600))
+
+	keys, err := LoadKeySet(context.Background(), path)
+	require.NoError(t, err)
+	public, err := keys.Key(context.Background(), "k1")
+	require.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(public))
+	// a token without key id uses the only key
+	_, err = keys.Key(context.Background(), "")
+	assert.NoError(t, err)
+	_, err = keys.Key(context.Background(), "k2")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = LoadKeySet(context.Background(), filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+	_, err = ParseKeySet([]byte(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`))
+	assert.Error(t, err)
+}
+
+func TestLoadKeySetFromURLPicksUpRotatedKeys(t *testing.T) {
+	first, second := newRSAKey(t), newRSAKey(t)
+	var document atomic.Value
+	document.Store(testKeySet(t, map[string]crypto.Signer{"k1": first}))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(document.Load().([]byte))
+	}))
+	defer server.Close()
+
+	keys, err := LoadKeySet(context.Background(), server.URL)
+	require.NoError(t, err)
+	_, err = keys.Key(context.Background(), "k1")
+	require.NoError(t, err)
+
+	document.Store(testKeySet(t, map[string]crypto.Signer{"k1": first, "k2": second}))
+	// unknown keys are not fetched again right away
+	_, err = keys.Key(context.Background(), "k2")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	keys.fetchedAt = keys.fetchedAt.Add(-keySetRefreshInterval)
+	public, err := keys.Key(context.Background(), "k2")
+	require.NoError(t, err)
+	assert.True(t, second.PublicKey.Equal(public))
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestKeySetServesKnownKeysWhileFetching(t *testing.T) {
+	key := newRSAKey(t)
+	document := testKeySet(t, map[string]crypto.Signer{"k1": key})
+	fetching, release := make(chan struct{}), make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 2 {
+			close(fetching)
+			<-release
+		}
+		_, _ = w.Write(document)
+	}))
+	defer server.Close()
+	// the fetch is released before the server is closed, even when the test fails
+	var once sync.Once
+	releaseFetch := func() { once.Do(func() { close(release) }) }
+	defer releaseFetch()
+
+	keys, err := LoadKeySet(context.Background(), server.URL)
+	require.NoError(t, err)
+	stale := keys.fetchedAt.Add(-keySetMaxAge)
+	keys.fetchedAt = stale
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := keys.Key(context.Background(), "k1")
+		refreshed <- err
+	}()
+	<-fetching
+
+	// the next requests read the stale time before the first one set it, they must not wait for its fetch
+	keys.mu.Lock()
+	keys.fetchedAt = stale
+	keys.mu.Unlock()
+	known := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := keys.Key(context.Background(), "k1")
+			known <- err
+		}()
+	}
+	for range 2 {
+		select {
+		case err := <-known:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("the known key waited for the key set fetch")
+		}
+	}
+
+	releaseFetch()
+	assert.NoError(t, <-refreshed)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// a key that is still unknown waits for a fetch
+	keys.fetchedAt = keys.fetchedAt.Add(-keySetRefreshInterval)
+	_, err = keys.Key(context.Background(), "k2")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, int32(3), fetches.Load())
+}
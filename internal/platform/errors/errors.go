@@ -5,11 +5,37 @@ type ErrorCode int
 const (
 	InternalServerError ErrorCode = iota - 1001
 	BadRequest          ErrorCode = iota - 2001
+	NotFound            ErrorCode = iota - 3001
+	Conflict            ErrorCode = iota - 4001
+	ServiceUnavailable  ErrorCode = iota - 5001
+	InvalidAlias        ErrorCode = iota - 6001
+	Gone                ErrorCode = iota - 7001
+	ClickLimitReached   ErrorCode = iota - 8001
+	Forbidden           ErrorCode = iota - 9001
+	Unauthorized        ErrorCode = iota - 10001
+	InsufficientScope   ErrorCode = iota - 11001
+	KeyNotFound         ErrorCode = iota - 12001
+	InvalidWorkspace    ErrorCode = iota - 13001
+	TooManyRequests     ErrorCode = iota - 14001
+	UserForbidden       ErrorCode = iota - 15001
 )
 
 var errorMessages = map[ErrorCode]string{
 	BadRequest:          "invalid request",
 	InternalServerError: "internal error",
+	NotFound:            "link not found",
+	Conflict:            "link already exists",
+	ServiceUnavailable:  "service unavailable",
+	InvalidAlias:        "alias must be 3 to 32 letters, digits, '-' or '_' and not a reserved word",
+	Gone:                "link has expired",
+	ClickLimitReached:   "link reached its click limit",
+	Forbidden:           "link belongs to another user",
+	Unauthorized:        "missing or invalid API key",
+	InsufficientScope:   "API key lacks the required scope",
+	KeyNotFound:         "API key not found",
+	InvalidWorkspace:    "workspace must be 2 to 32 lowercase letters, digits or '-'",
+	TooManyRequests:     "rate limit exceeded, retry later",
+	UserForbidden:       "cannot act for another user",
 }
 
 type CustomError struct {
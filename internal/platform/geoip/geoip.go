@@ -0,0 +1,103 @@
+// Package geoip resolves client IPs to locations with a local MaxMind database, it never calls a network service
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"io/fs"
+	"log"
+	"net"
+	"strings"
+)
+
+// Location is where an IP is registered, the fields the database does not know are empty
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code, such as "CL"
+	Country string
+	Region  string
+	City    string
+}
+
+// Known reports whether the country of the location was resolved
+func (l Location) Known() bool {
+	return l.Country != ""
+}
+
+// record holds the fields read from GeoIP2/GeoLite2 Country and City databases
+type record struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// Database looks up IPs in a memory mapped MaxMind database, a nil Database resolves every IP to an unknown location
+type Database struct {
+	reader *maxminddb.Reader
+}
+
+// Open opens the MaxMind database at path
+func Open(path string) (*Database, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open geoip database %s: %w", path, err)
+	}
+	return &Database{reader: reader}, nil
+}
+
+// Load opens the database at path, clicks are not geolocated when the path is empty or the file can not be read
+func Load(path string) *Database {
+	if path == "" {
+		return nil
+	}
+	db, err := Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("GeoIP database %s not found, clicks will not be geolocated", path)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Failed to load GeoIP database, clicks will not be geolocated | Error: %v", err)
+		return nil
+	}
+	return db
+}
+
+// Lookup resolves ip, private, malformed and unknown addresses have an unknown location
+func (d *Database) Lookup(ip string) Location {
+	if d == nil {
+		return Location{}
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.IsPrivate() || parsed.IsLoopback() {
+		return Location{}
+	}
+
+	var r record
+	if err := d.reader.Lookup(parsed, &r); err != nil {
+		log.Printf("Failed GeoIP lookup | Error: %v", err)
+		return Location{}
+	}
+	location := Location{Country: strings.ToUpper(r.Country.IsoCode), City: r.City.Names["en"]}
+	if len(r.Subdivisions) > 0 {
+		location.Region = r.Subdivisions[0].Names["en"]
+		if location.Region == "" {
+			location.Region = r.Subdivisions[0].IsoCode
+		}
+	}
+	return location
+}
+
+// Close unmaps the database
+func (d *Database) Close() error {
+	if d == nil {
+		return nil
+	}
+	return d.reader.Close()
+}
@@ -0,0 +1,124 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestDatabase writes an IPv4 MaxMind database where the addresses of prefix (a /24) resolve to data
+func writeTestDatabase(t *testing.T, prefix string, data []byte) string {
+	ip := net.ParseIP(prefix).To4()
+	const nodeCount = 24
+
+	var tree []byte
+	for i := 0; i < nodeCount; i++ {
+		next := uint32(i + 1)
+		if i == nodeCount-1 {
+			next = nodeCount + 16
+		}
+		left, right := uint32(nodeCount), uint32(nodeCount)
+		if ip[i/8]>>(7-i%8)&1 == 0 {
+			left = next
+		} else {
+			right = next
+		}
+		tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+	}
+
+	db := append(tree, make([]byte, 16)...)
+	db = append(db, data...)
+	db = append(db, "\xab\xcd\xefMaxMind.com"...)
+	db = append(db, encodeMap(
+		"node_count", encodeUint(6, nodeCount),
+		"record_size", encodeUint(5, 24),
+		"ip_version", encodeUint(5, 4),
+		"database_type", encodeString("GeoLite2-City"),
+		"languages", encodeArray(encodeString("en")),
+		"binary_format_major_version", encodeUint(5, 2),
+		"binary_format_minor_version", encodeUint(5, 0),
+		"build_epoch", append([]byte{8, 2}, binary.BigEndian.AppendUint64(nil, 1700000000)...),
+		"description", encodeMap("en", encodeString("test")),
+	)...)
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	require.NoError(t, os.WriteFile(path, db, 0o600))
+	return path
+}
+
+func encodeString(s string) []byte {
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+func encodeUint(kind byte, value uint32) []byte {
+	encoded := binary.BigEndian.AppendUint32(nil, value)
+	if kind == 5 {
+		encoded = encoded[2:]
+	}
+	return append([]byte{kind<<5 | byte(len(encoded))}, encoded...)
+}
+
+func encodeArray(items ...[]byte) []byte {
+	encoded := []byte{byte(len(items)), 4}
+	for _, item := range items {
+		encoded = append(encoded, item...)
+	}
+	return encoded
+}
+
+// encodeMap takes the keys followed by their encoded values
+func encodeMap(pairs ...any) []byte {
+	encoded := []byte{7<<5 | byte(len(pairs)/2)}
+	for i := 0; i < len(pairs); i += 2 {
+		encoded = append(encoded, encodeString(pairs[i].(string))...)
+		encoded = append(encoded, pairs[i+1].([]byte)...)
+	}
+	return encoded
+}
+
+func TestDatabaseLookup(t *testing.T) {
+	path := writeTestDatabase(t, "81.2.69.0", encodeMap(
+		"country", encodeMap("iso_code", encodeString("GB")),
+		"subdivisions", encodeArray(encodeMap("iso_code", encodeString("ENG"), "names", encodeMap("en", encodeString("England")))),
+		"city", encodeMap("names", encodeMap("en", encodeString("London"))),
+	))
+	db, err := Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	location := db.Lookup("81.2.69.160")
+	assert.Equal(t, Location{Country: "GB", Region: "England", City: "London"}, location)
+	assert.True(t, location.Known())
+	assert.Equal(t, Location{}, db.Lookup("81.2.70.1"))
+	assert.Equal(t, Location{}, db.Lookup("192.168.1.10"))
+	assert.Equal(t, Location{}, db.Lookup("not an ip"))
+	assert.Equal(t, Location{}, db.Lookup("2001:db8::1"))
+}
+
+func TestDatabaseLookupReadsCountryDatabases(t *testing.T) {
+	path := writeTestDatabase(t, "200.1.2.0", encodeMap("country", encodeMap("iso_code", encodeString("CL"))))
+	db := Load(path)
+	require.NotNil(t, db)
+	defer db.Close()
+
+	assert.Equal(t, Location{Country: "CL"}, db.Lookup("200.1.2.3"))
+}
+
+func TestLoadDegradesWithoutDatabase(t *testing.T) {
+	assert.Nil(t, Load(""))
+	assert.Nil(t, Load(filepath.Join(t.TempDir(), "missing.mmdb")))
+
+	invalid := filepath.Join(t.TempDir(), "invalid.mmdb")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a database"), 0o600))
+	assert.Nil(t, Load(invalid))
+
+	var db *Database
+	assert.Equal(t, Location{}, db.Lookup("81.2.69.160"))
+	assert.False(t, db.Lookup("81.2.69.160").Known())
+	assert.NoError(t, db.Close())
+}
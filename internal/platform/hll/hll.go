@@ -0,0 +1,97 @@
+// Package hll implements the HyperLogLog cardinality sketch used by the storage backends without native support
+package hll
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	// Precision is the number of hash bits selecting a register, the standard error is 1.04/sqrt(2^Precision), 1.6%
+	Precision = 12
+	registers = 1 << Precision
+)
+
+// ErrInvalidSketch is returned when unmarshalling data that is not a sketch
+var ErrInvalidSketch = errors.New("invalid hyperloglog sketch")
+
+// Sketch estimates the number of distinct values added to it in a fixed 4KB of memory
+type Sketch struct {
+	registers [registers]uint8
+}
+
+// New returns an empty sketch
+func New() *Sketch {
+	return &Sketch{}
+}
+
+// Add records value in the sketch
+func (s *Sketch) Add(value string) {
+	h := hash(value)
+	index := h >> (64 - Precision)
+	// the marker bit bounds the rank when the remaining bits are all zero
+	rank := uint8(bits.LeadingZeros64(h<<Precision|1<<(Precision-1))) + 1
+	if rank > s.registers[index] {
+		s.registers[index] = rank
+	}
+}
+
+// Merge adds the values of other to the sketch
+func (s *Sketch) Merge(other *Sketch) {
+	for i, rank := range other.registers {
+		if rank > s.registers[i] {
+			s.registers[i] = rank
+		}
+	}
+}
+
+// Count returns the estimated number of distinct values added to the sketch
+func (s *Sketch) Count() int64 {
+	sum := 0.0
+	zeros := 0
+	for _, rank := range s.registers {
+		sum += 1 / float64(uint64(1)<<rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(registers)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// linear counting is more accurate for small cardinalities
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+// MarshalBinary returns the registers of the sketch
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	data := make([]byte, registers)
+	copy(data, s.registers[:])
+	return data, nil
+}
+
+// UnmarshalBinary restores a sketch returned by MarshalBinary
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) != registers {
+		return ErrInvalidSketch
+	}
+	copy(s.registers[:], data)
+	return nil
+}
+
+// hash spreads the FNV-1a hash of value with the murmur3 finalizer, FNV alone leaves the high bits poorly mixed
+func hash(value string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,53 @@
+package hll
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketchCountIsExactForFewValues(t *testing.T) {
+	s := New()
+	assert.Zero(t, s.Count())
+
+	for i := 0; i < 3; i++ {
+		s.Add("a")
+		s.Add("b")
+	}
+	assert.Equal(t, int64(2), s.Count())
+}
+
+func TestSketchEstimatesLargeCardinalities(t *testing.T) {
+	for _, n := range []int{1000, 50000, 500000} {
+		s := New()
+		for i := 0; i < n; i++ {
+			s.Add(fmt.Sprintf("visitor-%d", i))
+		}
+		// three standard errors
+		assert.InEpsilon(t, n, s.Count(), 0.05, n)
+	}
+}
+
+func TestSketchMergeCountsTheUnion(t *testing.T) {
+	a, b := New(), New()
+	for i := 0; i < 1000; i++ {
+		a.Add(fmt.Sprintf("visitor-%d", i))
+		b.Add(fmt.Sprintf("visitor-%d", i+500))
+	}
+	a.Merge(b)
+	assert.InEpsilon(t, 1500, a.Count(), 0.05)
+}
+
+func TestSketchBinaryRoundTrip(t *testing.T) {
+	s := New()
+	s.Add("a")
+	data, err := s.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := New()
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, s.Count(), restored.Count())
+	assert.ErrorIs(t, restored.UnmarshalBinary([]byte("short")), ErrInvalidSketch)
+}
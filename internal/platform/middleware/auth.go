@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	stderrors "errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// keyTouchInterval is how stale the last used time of a key may get, it bounds the writes made for busy keys
+const keyTouchInterval = time.Minute
+
+// Authenticate authenticates the requests with the API key or JWT sent as "Authorization: Bearer <credential>" or in
+// the X-API-Key header and stores its auth.Principal in the context. adminKey, when set, is a bootstrap key with the
+// admin scope that is not stored and that acts as the operator of every workspace. tokens verifies the JWTs, they are
+// rejected when it is nil. Requests without valid credentials are rejected with 401
+func Authenticate(keys store.KeyRepository, adminKey string, tokens *auth.TokenVerifier) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := requestKey(ctx.Request)
+		if key == "" {
+			abortUnauthorized(ctx)
+			return
+		}
+		if tokens != nil && auth.IsToken(key) {
+			principal, err := tokens.Verify(ctx.Request.Context(), key)
+			if err != nil {
+				log.Printf("Rejected bearer token | Error: %v\n", err)
+				abortUnauthorized(ctx)
+				return
+			}
+			auth.SetPrincipal(ctx, principal)
+			ctx.Next()
+			return
+		}
+		if adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+			auth.SetPrincipal(ctx, &auth.Principal{Scopes: []string{auth.ScopeAdmin}, Operator: true})
+			ctx.Next()
+			return
+		}
+
+		id, secret, err := auth.ParseKey(key)
+		if err != nil {
+			abortUnauthorized(ctx)
+			return
+		}
+		stored, err := keys.GetKey(ctx.Request.Context(), id)
+		if stderrors.Is(err, store.ErrNotFound) {
+			abortUnauthorized(ctx)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to load API key | Error: %v - keyId: %s\n", err, id)
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, errors.NewCustomError(errors.ServiceUnavailable))
+			return
+		}
+		if stored.Revoked() || !auth.MatchSecret(secret, stored.Hash) {
+			abortUnauthorized(ctx)
+			return
+		}
+
+		now := time.Now().UTC()
+		if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= keyTouchInterval {
+			if err := keys.TouchKey(ctx.Request.Context(), id, now); err != nil {
+				log.Printf("Failed to record API key use | Error: %v - keyId: %s\n", err, id)
+			}
+		}
+		auth.SetPrincipal(ctx, &auth.Principal{
+			UserId:    stored.UserId,
+			KeyId:     stored.Id,
+			Scopes:    stored.Scopes,
+			Workspace: stored.Workspace,
+		})
+		ctx.Next()
+	}
+}
+
+// RequireScope rejects with 403 the requests whose principal lacks scope, it runs after Authenticate
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, ok := auth.FromContext(ctx)
+		if !ok {
+			abortUnauthorized(ctx)
+			return
+		}
+		if !principal.HasScope(scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewCustomError(errors.InsufficientScope))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func requestKey(request *http.Request) string {
+	if key := request.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(request.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func abortUnauthorized(ctx *gin.Context) {
+	ctx.Header("WWW-Authenticate", "Bearer")
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized, errors.NewCustomError(errors.Unauthorized))
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func issueTestKey(t *testing.T, repo store.KeyRepository, userId string, scopes ...string) (string, string) {
+	id, secret, key, err := auth.NewKey()
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveKey(context.Background(), &store.APIKey{
+		Id: id, Hash: auth.HashSecret(secret), UserId: userId, Scopes: scopes, CreatedAt: time.Now().UTC(),
+	}))
+	return id, key
+}
+
+func newTestAuthRouter(repo store.KeyRepository) *gin.Engine {
+	return newTestAuthRouterWithTokens(repo, nil)
+}
+
+func newTestAuthRouterWithTokens(repo store.KeyRepository, tokens *auth.TokenVerifier) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/links", Authenticate(repo, "bootstrap", tokens), RequireScope(auth.ScopeLinksRead), func(ctx *gin.Context) {
+		principal, _ := auth.FromContext(ctx)
+		ctx.String(http.StatusOK, principal.UserId)
+	})
+	return r
+}
+
+func serveWithKey(r *gin.Engine, header, value string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/links", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyAuthenticatesTheOwnerOfTheKey(t *testing.T) {
+	repo := store.NewMemoryStore()
+	id, key := issueTestKey(t, repo, "42", auth.ScopeLinksRead)
+	r := newTestAuthRouter(repo)
+
+	w := serveWithKey(r, "Authorization", "Bearer "+key)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "42", w.Body.String())
+	w = serveWithKey(r, "X-API-Key", key)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := repo.GetKey(context.Background(), id)
+	require.NoError(t, err)
+	require.NotNil(t, stored.LastUsedAt)
+	assert.WithinDuration(t, time.Now(), *stored.LastUsedAt, time.Minute)
+}
+
+func TestAPIKeyRejectsInvalidKeys(t *testing.T) {
+	repo := store.NewMemoryStore()
+	id, key := issueTestKey(t, repo, "42", auth.ScopeLinksRead)
+	r := newTestAuthRouter(repo)
+
+	for _, value := range []string{"", "Bearer", "Basic " + key, "Bearer sk_unknown_secret", "Bearer " + key + "x"} {
+		w := serveWithKey(r, "Authorization", value)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, value)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	}
+
+	require.NoError(t, repo.RevokeKey(context.Background(), id, time.Now()))
+	assert.Equal(t, http.StatusUnauthorized, serveWithKey(r, "Authorization", "Bearer "+key).Code)
+}
+
+func TestRequireScope(t *testing.T) {
+	repo := store.NewMemoryStore()
+	_, writer := issueTestKey(t, repo, "42", auth.ScopeLinksWrite)
+	_, admin := issueTestKey(t, repo, "7", auth.ScopeAdmin)
+	r := newTestAuthRouter(repo)
+
+	assert.Equal(t, http.StatusForbidden, serveWithKey(r, "X-API-Key", writer).Code)
+	assert.Equal(t, http.StatusOK, serveWithKey(r, "X-API-Key", admin).Code)
+	// the bootstrap key is an admin without user
+	w := serveWithKey(r, "X-API-Key", "bootstrap")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestAuthenticateAcceptsBearerTokens(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keys, err := auth.ParseKeySet([]byte(`{"keys":[{"kty":"OKP","crv":"Ed25519","kid":"sso","x":"` +
+		base64.RawURLEncoding.EncodeToString(public) + `"}]}`))
+	require.NoError(t, err)
+	tokens := auth.NewTokenVerifier(keys, auth.TokenOptions{
+		Issuer: "https://sso.example.com", Audience: "url-shortener", RolesClaim: "roles",
+	})
+	r := newTestAuthRouterWithTokens(store.NewMemoryStore(), tokens)
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+		token.Header["kid"] = "sso"
+		signed, err := token.SignedString(private)
+		require.NoError(t, err)
+		return signed
+	}
+	claims := jwt.MapClaims{
+		"iss": "https://sso.example.com", "aud": "url-shortener", "sub": "ana",
+		"exp": time.Now().Add(time.Hour).Unix(), "roles": []string{auth.ScopeLinksRead},
+	}
+
+	w := serveWithKey(r, "Authorization", "Bearer "+sign(claims))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ana", w.Body.String())
+
+	claims["aud"] = "other"
+	assert.Equal(t, http.StatusUnauthorized, serveWithKey(r, "Authorization", "Bearer "+sign(claims)).Code)
+
+	claims["aud"], claims["roles"] = "url-shortener", []string{}
+	assert.Equal(t, http.StatusForbidden, serveWithKey(r, "Authorization", "Bearer "+sign(claims)).Code)
+
+	// without a verifier tokens are rejected like unknown keys
+	r = newTestAuthRouter(store.NewMemoryStore())
+	claims["roles"] = []string{auth.ScopeLinksRead}
+	assert.Equal(t, http.StatusUnauthorized, serveWithKey(r, "Authorization", "Bearer "+sign(claims)).Code)
+}
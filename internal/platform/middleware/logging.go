@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"fmt"
+	"github.com/alexperezortuno/go-url-shortner/internal/commons"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/useragent"
 	"github.com/gin-gonic/gin"
 	"time"
 )
@@ -27,6 +29,14 @@ func Logging() gin.HandlerFunc {
 		method := c.Request.Method
 		statusCode := c.Writer.Status()
 		userAgent := c.Request.UserAgent()
+		// redirects tell whether the client is a human or which bot it is
+		if value, ok := c.Get(commons.AgentContextKey); ok {
+			if agent, ok := value.(useragent.Agent); ok && agent.IsBot() {
+				userAgent = fmt.Sprintf("%s [%s: %s]", userAgent, agent.Class, agent.Bot)
+			} else if ok {
+				userAgent = fmt.Sprintf("%s [%s]", userAgent, agent.Class)
+			}
+		}
 
 		fmt.Printf("%v | %3d | %s | %15s | %-7s %#v | %13v\n",
 			timestamp.Format("2006/01/02 - 15:04:05"),
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/ratelimit"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/gin-gonic/gin"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimit rejects with 429 the requests over limit. The requests are counted per API key, per user for JWTs and per
+// client IP otherwise, apart for each workspace and each name, so every route group gets its own limit. Every response
+// carries the X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers, rejected ones also Retry-After.
+// The requests are let through when the limiter fails, a limiter outage must not take the service down
+func RateLimit(limiter ratelimit.Limiter, name string, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if limiter == nil || !limit.Enabled() {
+			ctx.Next()
+			return
+		}
+
+		key := rateLimitKey(ctx, name)
+		result, err := limiter.Allow(ctx.Request.Context(), key, limit)
+		if err != nil {
+			log.Printf("Failed to check rate limit | Error: %v - key: %s\n", err, key)
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Header("X-RateLimit-Reset", seconds(result.Reset))
+		if !result.Allowed {
+			ctx.Header("Retry-After", seconds(result.RetryAfter))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errors.NewCustomError(errors.TooManyRequests))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// rateLimitKey identifies the client of the request within its workspace
+func rateLimitKey(ctx *gin.Context, name string) string {
+	subject := "ip:" + ctx.ClientIP()
+	if principal, ok := auth.FromContext(ctx); ok {
+		switch {
+		case principal.KeyId != "":
+			subject = "key:" + principal.KeyId
+		case principal.UserId != "":
+			subject = "user:" + principal.UserId
+		}
+	}
+	return name + ":" + store.WorkspaceFrom(ctx.Request.Context()) + ":" + subject
+}
+
+// seconds formats d as whole seconds rounded up, the clients waiting that long are never early
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
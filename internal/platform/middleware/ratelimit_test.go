@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func newTestRateLimitRouter(limiter ratelimit.Limiter, principal *auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/links", func(ctx *gin.Context) {
+		if principal != nil {
+			auth.SetPrincipal(ctx, principal)
+		}
+	}, Workspace(), RateLimit(limiter, "create", ratelimit.Limit{Requests: 2, Window: time.Minute}),
+		func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+	return r
+}
+
+func serveFrom(r *gin.Engine, ip, workspace string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/links", nil)
+	req.RemoteAddr = ip + ":1234"
+	if workspace != "" {
+		req.Header.Set("X-Workspace", workspace)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitRejectsClientsOverTheLimit(t *testing.T) {
+	r := newTestRateLimitRouter(ratelimit.NewMemoryLimiter(), nil)
+
+	w := serveFrom(r, "192.0.2.1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, serveFrom(r, "192.0.2.1", "").Code)
+
+	w = serveFrom(r, "192.0.2.1", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// other clients and other workspaces have their own limits
+	assert.Equal(t, http.StatusOK, serveFrom(r, "192.0.2.2", "").Code)
+	assert.Equal(t, http.StatusOK, serveFrom(r, "192.0.2.1", "eng").Code)
+}
+
+func TestRateLimitIgnoresForwardedForFromUntrustedClients(t *testing.T) {
+	r := newTestRateLimitRouter(ratelimit.NewMemoryLimiter(), nil)
+	require.NoError(t, r.SetTrustedProxies(nil))
+	serveForwarded := func(forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/links", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, serveForwarded("198.51.100.1"))
+	require.Equal(t, http.StatusOK, serveForwarded("198.51.100.2"))
+	assert.Equal(t, http.StatusTooManyRequests, serveForwarded("198.51.100.3"))
+
+	// behind a trusted proxy every forwarded client has its own limit
+	require.NoError(t, r.SetTrustedProxies([]string{"192.0.2.1"}))
+	assert.Equal(t, http.StatusOK, serveForwarded("198.51.100.4"))
+}
+
+func TestRateLimitCountsRequestsPerKey(t *testing.T) {
+	limiter := ratelimit.NewMemoryLimiter()
+	r := newTestRateLimitRouter(limiter, &auth.Principal{UserId: "1", KeyId: "k1"})
+	other := newTestRateLimitRouter(limiter, &auth.Principal{UserId: "1", KeyId: "k2"})
+
+	// the same key is limited from every IP
+	require.Equal(t, http.StatusOK, serveFrom(r, "192.0.2.1", "").Code)
+	require.Equal(t, http.StatusOK, serveFrom(r, "192.0.2.2", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveFrom(r, "192.0.2.3", "").Code)
+	assert.Equal(t, http.StatusOK, serveFrom(other, "192.0.2.1", "").Code)
+}
+
+func TestRateLimitLetsRequestsThroughWhenTheLimiterFails(t *testing.T) {
+	r := newTestRateLimitRouter(failingLimiter{}, nil)
+
+	for i := 0; i < 3; i++ {
+		w := serveFrom(r, "192.0.2.1", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/alexperezortuno/go-url-shortner/internal/commons"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// Workspace binds the request to the workspace of its principal, so the handlers only see the links of that workspace.
+// The operator and the requests of an open API name their workspace in the X-Workspace header, the default workspace
+// is used without it. Invalid workspace names are rejected with 400
+func Workspace() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		workspace := store.DefaultWorkspace
+		principal, ok := auth.FromContext(ctx)
+		if ok && !principal.Operator {
+			workspace = principal.Workspace
+		} else if requested := ctx.GetHeader(commons.WorkspaceHeader); requested != "" {
+			if !store.ValidWorkspace(requested) {
+				ctx.AbortWithStatusJSON(http.StatusBadRequest, errors.NewCustomError(errors.InvalidWorkspace))
+				return
+			}
+			workspace = requested
+		}
+		ctx.Request = ctx.Request.WithContext(store.WithWorkspace(ctx.Request.Context(), workspace))
+		ctx.Next()
+	}
+}
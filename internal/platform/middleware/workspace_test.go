@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWorkspaceRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/links", append(handlers, Workspace(), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, store.WorkspaceFrom(ctx.Request.Context()))
+	})...)
+	return r
+}
+
+func serveInWorkspace(r *gin.Engine, key, workspace string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/links", nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	if workspace != "" {
+		req.Header.Set("X-Workspace", workspace)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestWorkspaceBindsKeysToTheirWorkspace(t *testing.T) {
+	repo := store.NewMemoryStore()
+	id, secret, key, err := auth.NewKey()
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveKey(context.Background(), &store.APIKey{
+		Id: id, Hash: auth.HashSecret(secret), UserId: "42", Workspace: "eng", Scopes: []string{auth.ScopeLinksRead},
+		CreatedAt: time.Now().UTC(),
+	}))
+	_, legacy := issueTestKey(t, repo, "42", auth.ScopeLinksRead)
+	r := newTestWorkspaceRouter(Authenticate(repo, "bootstrap", nil))
+
+	// the header can not move a key out of its workspace
+	w := serveInWorkspace(r, key, "sales")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "eng", w.Body.String())
+	w = serveInWorkspace(r, legacy, "sales")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, store.DefaultWorkspace, w.Body.String())
+
+	// the operator picks the workspace of each request
+	w = serveInWorkspace(r, "bootstrap", "sales")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "sales", w.Body.String())
+	w = serveInWorkspace(r, "bootstrap", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, store.DefaultWorkspace, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, serveInWorkspace(r, "bootstrap", "Sales/1").Code)
+}
+
+func TestWorkspaceReadsTheHeaderWhenTheAPIIsOpen(t *testing.T) {
+	r := newTestWorkspaceRouter()
+
+	w := serveInWorkspace(r, "", "eng")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "eng", w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, serveInWorkspace(r, "", "x").Code)
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the counters of the idle keys are dropped
+const sweepInterval = time.Minute
+
+// MemoryLimiter keeps the counters in the process, the limits only hold for a single node
+type MemoryLimiter struct {
+	mu       sync.Mutex
+	counters map[counterKey]*counter
+	swept    time.Time
+	now      func() time.Time
+}
+
+// counterKey identifies the counters of a key for a window size, every limit of a key is counted apart
+type counterKey struct {
+	key  string
+	size time.Duration
+}
+
+// counter holds the counts of a key in its current fixed window and the one before
+type counter struct {
+	window   int64
+	previous int64
+	current  int64
+}
+
+// NewMemoryLimiter returns a limiter without counters
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{counters: map[counterKey]*counter{}, now: time.Now}
+}
+
+var _ Limiter = (*MemoryLimiter)(nil)
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	now := l.now()
+	index, elapsed := window(limit, now)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	c := l.counters[counterKey{key: key, size: limit.Window}]
+	if c == nil {
+		c = &counter{window: index}
+		l.counters[counterKey{key: key, size: limit.Window}] = c
+	}
+	switch c.window {
+	case index:
+	case index - 1:
+		c.window, c.previous, c.current = index, c.current, 0
+	default:
+		c.window, c.previous, c.current = index, 0, 0
+	}
+
+	ok := allowed(limit, c.previous, c.current, elapsed)
+	if ok {
+		c.current++
+	}
+	return decide(limit, ok, c.previous, c.current, elapsed), nil
+}
+
+// sweep drops the counters whose windows both ended, they would be reset on their next request anyway
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+	for key, c := range l.counters {
+		if index, _ := window(Limit{Window: key.size}, now); c.window < index-1 {
+			delete(l.counters, key)
+		}
+	}
+}
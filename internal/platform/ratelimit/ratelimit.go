@@ -0,0 +1,116 @@
+// Package ratelimit bounds how many requests a client can make in a sliding window of time
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/alexperezortuno/go-url-shortner/internal/config"
+	"github.com/go-redis/redis/v9"
+	"math"
+	"time"
+)
+
+const (
+	DriverMemory = "memory"
+	DriverRedis  = "redis"
+)
+
+// Limit allows Requests requests per Window, a zero Limit allows every request
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Enabled reports whether the limit rejects any request
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Window > 0
+}
+
+// Result is the decision taken for a request and the state of the limit after it
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is how many more requests are allowed right now
+	Remaining int
+	// Reset is when the current window ends, RetryAfter when a rejected request would be allowed
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter counts the requests of each key. The counts are a sliding window approximated from the counts of the
+// current and the previous fixed windows, weighted by how much of the previous window is still in the sliding one
+type Limiter interface {
+	// Allow counts a request of key when limit allows it
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// New returns the limiter of the configured driver. Without RATE_LIMIT_DRIVER the limits are kept in Redis when it
+// is the storage driver, so they hold across replicas, and in memory otherwise
+func New(cfg *config.Config) (Limiter, error) {
+	driver := cfg.RateLimitDriver
+	if driver == "" {
+		driver = DriverMemory
+		if cfg.StorageDriver == DriverRedis {
+			driver = DriverRedis
+		}
+	}
+
+	switch driver {
+	case DriverMemory:
+		return NewMemoryLimiter(), nil
+	case DriverRedis:
+		return NewRedisLimiter(redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPass,
+			DB:       cfg.RedisDb,
+		})), nil
+	default:
+		return nil, fmt.Errorf("invalid rate limit driver: %s", driver)
+	}
+}
+
+// window returns the index of the fixed window holding now and how much of it has elapsed
+func window(limit Limit, now time.Time) (int64, time.Duration) {
+	nanos := now.UnixNano()
+	return nanos / int64(limit.Window), time.Duration(nanos % int64(limit.Window))
+}
+
+// decide builds the result of a request from the counts of the previous and current windows. current already
+// includes the request when it was allowed
+func decide(limit Limit, allowed bool, previous, current int64, elapsed time.Duration) Result {
+	weight := float64(limit.Window-elapsed) / float64(limit.Window)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: max(0, int(math.Floor(float64(limit.Requests)-float64(previous)*weight-float64(current)))),
+		Reset:     limit.Window - elapsed,
+	}
+	if !allowed {
+		result.RetryAfter = retryAfter(limit, previous, current, elapsed)
+	}
+	return result
+}
+
+// allowed reports whether one more request fits in the sliding window
+func allowed(limit Limit, previous, current int64, elapsed time.Duration) bool {
+	weight := float64(limit.Window-elapsed) / float64(limit.Window)
+	return float64(previous)*weight+float64(current)+1 <= float64(limit.Requests)
+}
+
+// retryAfter is how long until one more request fits in the sliding window, assuming no other request is made
+func retryAfter(limit Limit, previous, current int64, elapsed time.Duration) time.Duration {
+	window := float64(limit.Window)
+	free := float64(limit.Requests - 1)
+	// the previous window slides out before the current one ends
+	if float64(current) <= free && previous > 0 {
+		wait := time.Duration(window-(free-float64(current))*window/float64(previous)) - elapsed
+		if wait < limit.Window-elapsed {
+			return max(wait, 0)
+		}
+	}
+	// otherwise the current window becomes the previous one and has to slide out in turn
+	if current == 0 {
+		return limit.Window - elapsed
+	}
+	return limit.Window - elapsed + max(time.Duration(window-free*window/float64(current)), 0)
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time shared by the limiter under test
+type clock struct {
+	time time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.time
+}
+
+// assertSlidingWindow runs the same scenarios against any limiter
+func assertSlidingWindow(t *testing.T, limiter Limiter, clock *clock) {
+	ctx := context.Background()
+	limit := Limit{Requests: 3, Window: time.Minute}
+	clock.time = time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(ctx, "ip:192.0.2.1", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, i, result.Remaining)
+		assert.Equal(t, time.Minute, result.Reset)
+	}
+	result, err := limiter.Allow(ctx, "ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Zero(t, result.Remaining)
+	// the 3 requests of this window keep weighing on the next one until a third of it elapsed
+	assert.Equal(t, time.Minute+20*time.Second, result.RetryAfter)
+
+	// the keys and the limits are counted apart
+	result, err = limiter.Allow(ctx, "ip:192.0.2.2", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	result, err = limiter.Allow(ctx, "ip:192.0.2.1", Limit{Requests: 1, Window: time.Hour})
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	clock.time = clock.time.Add(time.Minute + 10*time.Second)
+	result, err = limiter.Allow(ctx, "ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 10*time.Second, result.RetryAfter)
+	assert.Equal(t, 50*time.Second, result.Reset)
+
+	clock.time = clock.time.Add(10 * time.Second)
+	result, err = limiter.Allow(ctx, "ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Zero(t, result.Remaining)
+
+	// both windows are forgotten after two windows without requests
+	clock.time = clock.time.Add(2 * time.Minute)
+	result, err = limiter.Allow(ctx, "ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+
+	result, err = limiter.Allow(ctx, "ip:192.0.2.1", Limit{})
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
+
+func TestMemoryLimiterSlidingWindow(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	clock := &clock{}
+	limiter.now = clock.now
+	assertSlidingWindow(t, limiter, clock)
+}
+
+func TestMemoryLimiterSweepsIdleKeys(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	clock := &clock{time: time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)}
+	limiter.now = clock.now
+	limit := Limit{Requests: 1, Window: time.Second}
+
+	_, err := limiter.Allow(context.Background(), "a", limit)
+	require.NoError(t, err)
+	clock.time = clock.time.Add(sweepInterval)
+	_, err = limiter.Allow(context.Background(), "b", limit)
+	require.NoError(t, err)
+	assert.NotContains(t, limiter.counters, counterKey{key: "a", size: time.Second})
+	assert.Contains(t, limiter.counters, counterKey{key: "b", size: time.Second})
+}
+
+func TestRedisLimiterSlidingWindow(t *testing.T) {
+	mr := miniredis.RunT(t)
+	limiter := NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	clock := &clock{}
+	limiter.now = clock.now
+	assertSlidingWindow(t, limiter, clock)
+	// the counters expire once they can not weigh on the sliding window anymore
+	for _, key := range mr.Keys() {
+		assert.Contains(t, []time.Duration{2 * time.Minute, 2 * time.Hour}, mr.TTL(key), key)
+	}
+}
+
+func TestRedisLimiterReturnsErrorWhenRedisIsDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	limiter := NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	mr.Close()
+
+	_, err := limiter.Allow(context.Background(), "ip:192.0.2.1", Limit{Requests: 1, Window: time.Minute})
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v9"
+	"time"
+)
+
+// keyPrefix starts the keys of the counters, apart from the keys of the links
+const keyPrefix = "ratelimit:"
+
+// slidingWindow counts a request in the current window of KEYS[1] when the weighted count of the previous window
+// KEYS[2] and the current one leaves room for it. The script runs atomically, so the replicas sharing the Redis
+// server share the limits
+var slidingWindow = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+local limit, window, elapsed = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+if previous * (window - elapsed) / window + current + 1 > limit then
+	return {0, previous, current}
+end
+current = redis.call('INCR', KEYS[1])
+if current == 1 then
+	redis.call('PEXPIRE', KEYS[1], window * 2)
+end
+return {1, previous, current}
+`)
+
+// RedisLimiter keeps the counters in Redis. The windows are computed from the clock of each replica, which should be
+// kept in sync
+type RedisLimiter struct {
+	redisClient *redis.Client
+	now         func() time.Time
+}
+
+// NewRedisLimiter keeps the counters with an already configured Redis client
+func NewRedisLimiter(rdb *redis.Client) *RedisLimiter {
+	return &RedisLimiter{redisClient: rdb, now: time.Now}
+}
+
+var _ Limiter = (*RedisLimiter)(nil)
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	index, elapsed := window(limit, l.now())
+	prefix := fmt.Sprintf("%s%s:%d:", keyPrefix, key, limit.Window.Milliseconds())
+	counts, err := slidingWindow.Run(ctx, l.redisClient,
+		[]string{fmt.Sprint(prefix, index), fmt.Sprint(prefix, index-1)},
+		limit.Requests, limit.Window.Milliseconds(), elapsed.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit %s: %w", key, err)
+	}
+	return decide(limit, counts[0] == 1, counts[1], counts[2], elapsed), nil
+}
+
+// Close closes the Redis client
+func (l *RedisLimiter) Close() error {
+	return l.redisClient.Close()
+}
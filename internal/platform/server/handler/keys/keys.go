@@ -0,0 +1,130 @@
+package keys
+
+import (
+	stderrors "errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+// KeyIssueRequest describes the API key to issue, the key acts as UserId on the links. The key is granted the scopes of
+// Role and Scopes, at least one of them is required
+type KeyIssueRequest struct {
+	UserId string   `json:"user_id" binding:"required"`
+	Name   string   `json:"name"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes"`
+}
+
+// IssueKey creates an API key in the workspace of the request and returns it, the key can not be read again afterwards
+func IssueKey(keys store.KeyRepository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var request KeyIssueRequest
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
+		if _, ok := auth.Roles[request.Role]; request.Role != "" && !ok || len(request.Scopes) > 0 &&
+			!auth.ValidScopes(request.Scopes) || request.Role == "" && len(request.Scopes) == 0 {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
+
+		id, secret, key, err := auth.NewKey()
+		if err != nil {
+			log.Printf("Failed IssueKey | Error: %v\n", err)
+			ctx.JSON(http.StatusInternalServerError, errors.NewCustomError(errors.InternalServerError))
+			return
+		}
+		apiKey := &store.APIKey{
+			Id:        id,
+			Hash:      auth.HashSecret(secret),
+			UserId:    request.UserId,
+			Workspace: store.WorkspaceFrom(ctx.Request.Context()),
+			Name:      request.Name,
+			Scopes:    auth.Grants(append([]string{request.Role}, request.Scopes...)),
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := keys.SaveKey(ctx.Request.Context(), apiKey); err != nil {
+			log.Printf("Failed IssueKey | Error: %v - keyId: %s\n", err, id)
+			abortWithStoreError(ctx, err)
+			return
+		}
+
+		response := keyResponse(apiKey)
+		response["key"] = key
+		ctx.JSON(http.StatusCreated, response)
+	}
+}
+
+// GetKey returns the metadata of an API key, including when it was last used
+func GetKey(keys store.KeyRepository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		apiKey, err := workspaceKey(ctx, keys, ctx.Param("id"))
+		if err != nil {
+			log.Printf("Failed GetKey | Error: %v - keyId: %s\n", err, ctx.Param("id"))
+			abortWithStoreError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, keyResponse(apiKey))
+	}
+}
+
+// RevokeKey revokes an API key, the requests using it are rejected from then on
+func RevokeKey(keys store.KeyRepository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		_, err := workspaceKey(ctx, keys, ctx.Param("id"))
+		if err == nil {
+			err = keys.RevokeKey(ctx.Request.Context(), ctx.Param("id"), time.Now().UTC())
+		}
+		if err != nil {
+			log.Printf("Failed RevokeKey | Error: %v - keyId: %s\n", err, ctx.Param("id"))
+			abortWithStoreError(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// workspaceKey loads the key with id, the keys of other workspaces are reported as not found
+func workspaceKey(ctx *gin.Context, keys store.KeyRepository, id string) (*store.APIKey, error) {
+	apiKey, err := keys.GetKey(ctx.Request.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.Workspace != store.WorkspaceFrom(ctx.Request.Context()) {
+		return nil, store.ErrNotFound
+	}
+	return apiKey, nil
+}
+
+// keyResponse is the public view of a key, the hash of the secret is never returned
+func keyResponse(apiKey *store.APIKey) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           apiKey.Id,
+		"user_id":      apiKey.UserId,
+		"workspace":    apiKey.Workspace,
+		"name":         apiKey.Name,
+		"scopes":       apiKey.Scopes,
+		"created_at":   apiKey.CreatedAt,
+		"last_used_at": apiKey.LastUsedAt,
+		"revoked_at":   apiKey.RevokedAt,
+	}
+}
+
+func abortWithStoreError(ctx *gin.Context, err error) {
+	switch {
+	case stderrors.Is(err, store.ErrNotFound):
+		ctx.AbortWithStatusJSON(http.StatusNotFound, errors.NewCustomError(errors.KeyNotFound))
+	case stderrors.Is(err, store.ErrConflict):
+		ctx.AbortWithStatusJSON(http.StatusConflict, errors.NewCustomError(errors.Conflict))
+	case stderrors.Is(err, store.ErrUnavailable):
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, errors.NewCustomError(errors.ServiceUnavailable))
+	default:
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errors.NewCustomError(errors.InternalServerError))
+	}
+}
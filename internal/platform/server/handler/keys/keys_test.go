@@ -0,0 +1,132 @@
+package keys
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(repo store.KeyRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/admin/keys", IssueKey(repo))
+	r.GET("/admin/keys/:id", GetKey(repo))
+	r.DELETE("/admin/keys/:id", RevokeKey(repo))
+	return r
+}
+
+func TestIssueGetAndRevokeKey(t *testing.T) {
+	repo := store.NewMemoryStore()
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/keys",
+		strings.NewReader(`{"user_id":"42","name":"ci","scopes":["links:read","links:write"]}`)))
+	require.Equal(t, http.StatusCreated, w.Code)
+	var issued map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	assert.Equal(t, "42", issued["user_id"])
+	assert.NotContains(t, issued, "hash")
+
+	id, secret, err := auth.ParseKey(issued["key"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, issued["id"], id)
+	stored, err := repo.GetKey(t.Context(), id)
+	require.NoError(t, err)
+	assert.True(t, auth.MatchSecret(secret, stored.Hash))
+	assert.NotContains(t, stored.Hash, secret)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/keys/"+id, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"key"`)
+	assert.Contains(t, w.Body.String(), `"revoked_at":null`)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/keys/"+id, nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	stored, err = repo.GetKey(t.Context(), id)
+	require.NoError(t, err)
+	assert.True(t, stored.Revoked())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/keys/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestIssueKeyValidatesTheRequest(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	for _, body := range []string{
+		`{"scopes":["links:read"]}`,
+		`{"user_id":"42"}`,
+		`{"user_id":"42","scopes":[]}`,
+		`{"user_id":"42","scopes":["links:delete"]}`,
+		`{"user_id":"42","role":"owner"}`,
+		`{"user_id":"42","role":"viewer","scopes":["links:delete"]}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/keys", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestIssueKeyWithRole(t *testing.T) {
+	repo := store.NewMemoryStore()
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/keys",
+		strings.NewReader(`{"user_id":"42","role":"viewer","scopes":["links:write"]}`)))
+	require.Equal(t, http.StatusCreated, w.Code)
+	var issued map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+
+	stored, err := repo.GetKey(t.Context(), issued["id"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, []string{auth.ScopeLinksRead, auth.ScopeStatsRead, auth.ScopeLinksWrite}, stored.Scopes)
+}
+
+func TestKeysAreScopedToTheWorkspaceOfTheRequest(t *testing.T) {
+	repo := store.NewMemoryStore()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(store.WithWorkspace(ctx.Request.Context(), ctx.GetHeader("X-Workspace")))
+	})
+	r.POST("/admin/keys", IssueKey(repo))
+	r.GET("/admin/keys/:id", GetKey(repo))
+	r.DELETE("/admin/keys/:id", RevokeKey(repo))
+	serve := func(method, path, workspace, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Workspace", workspace)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/admin/keys", "eng", `{"user_id":"42","role":"viewer"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var issued map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	assert.Equal(t, "eng", issued["workspace"])
+	id := issued["id"].(string)
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/admin/keys/"+id, "sales", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/admin/keys/"+id, "", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/admin/keys/"+id, "sales", "").Code)
+	stored, err := repo.GetKey(t.Context(), id)
+	require.NoError(t, err)
+	assert.False(t, stored.Revoked())
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/admin/keys/"+id, "eng", "").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/admin/keys/"+id, "eng", "").Code)
+}
@@ -0,0 +1,61 @@
+package shortner
+
+import (
+	"fmt"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/errors"
+	"github.com/gin-gonic/gin"
+	"html"
+	"log"
+	"os"
+)
+
+// linkPage is the HTML page answered to browsers following a link that can not be redirected, formatted with its
+// title and message
+const linkPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>%[1]s</title>
+</head>
+<body>
+  <h1>%[1]s</h1>
+  <p>%[2]s</p>
+</body>
+</html>
+`
+
+var defaultNotFoundPage = fmt.Sprintf(linkPage, "Link not found", "The short link you followed does not exist.")
+
+// defaultPreviewPage is answered to the bots following a link with a click limit, instead of redirecting them
+var defaultPreviewPage = fmt.Sprintf(linkPage, "Short link",
+	"This short link can only be followed a limited number of times, open it in a browser to follow it.")
+
+// gonePage is the page of the links that exist but can not be followed anymore, it tells why with the message of code
+func gonePage(code errors.ErrorCode) []byte {
+	return []byte(fmt.Sprintf(linkPage, "Link no longer available",
+		html.EscapeString("The short link you followed is no longer available: "+errors.GetErrorMessage(code)+".")))
+}
+
+// loadNotFoundPage reads the configured HTML page, the default page is used when it is not set or cannot be read
+func loadNotFoundPage(path string) []byte {
+	if path == "" {
+		return []byte(defaultNotFoundPage)
+	}
+
+	page, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed loadNotFoundPage | Error: %v - path: %s\n", err, path)
+		return []byte(defaultNotFoundPage)
+	}
+	return page
+}
+
+// abortWithLinkError answers browsers with the HTML page and API clients with a JSON error
+func abortWithLinkError(ctx *gin.Context, status int, code errors.ErrorCode, page []byte) {
+	if ctx.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		ctx.Data(status, "text/html; charset=utf-8", page)
+		ctx.Abort()
+		return
+	}
+	ctx.AbortWithStatusJSON(status, errors.NewCustomError(code))
+}
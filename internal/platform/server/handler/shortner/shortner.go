@@ -1,58 +1,630 @@
 package shortner
 
 import (
+	stderrors "errors"
 	"fmt"
 	"github.com/alexperezortuno/go-url-shortner/internal/commons"
 	"github.com/alexperezortuno/go-url-shortner/internal/config"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/analytics"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
 	"github.com/alexperezortuno/go-url-shortner/internal/platform/errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/geoip"
 	"github.com/alexperezortuno/go-url-shortner/internal/platform/shortener"
 	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/useragent"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 )
 
 type URLCreationRequest struct {
 	LongURL string `json:"long_url" binding:"required"`
-	UserId  string `json:"user_id" binding:"required"`
+	// UserId is ignored when the request is authenticated with the API key of a user, the key owns the link
+	UserId   string            `json:"user_id"`
+	Alias    string            `json:"alias"`
+	Metadata map[string]string `json:"metadata"`
+	Tags     []string          `json:"tags"`
+	// Generator selects the code generation strategy, the configured default is used when it is omitted
+	Generator string `json:"generator"`
+	// RedirectStatus is one of commons.RedirectStatuses, the configured default is used when it is omitted
+	RedirectStatus int `json:"redirect_status"`
+	// ExpiresAt and TTL are exclusive, TTL is a duration such as "72h" or "never"
+	ExpiresAt *time.Time `json:"expires_at"`
+	TTL       string     `json:"ttl"`
+	// MaxClicks limits how many redirects the link serves before returning 410, zero means unlimited
+	MaxClicks int64 `json:"max_clicks"`
 }
 
-func CreateShortURL(cfg *config.Config) gin.HandlerFunc {
+// URLUpdateRequest changes an existing link, omitted fields keep their current value
+type URLUpdateRequest struct {
+	// UserId is ignored when the request is authenticated with the API key of a user
+	UserId  string    `json:"user_id"`
+	LongURL *string   `json:"long_url"`
+	Tags    *[]string `json:"tags"`
+	// RedirectStatus 0 restores the configured default
+	RedirectStatus *int `json:"redirect_status"`
+	// ExpiresAt and TTL are exclusive, TTL "never" removes the expiration
+	ExpiresAt *time.Time `json:"expires_at"`
+	TTL       string     `json:"ttl"`
+}
+
+func CreateShortURL(cfg *config.Config, repo store.LinkRepository, generators map[string]shortener.Generator) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if !authorize(ctx, auth.ScopeLinksWrite) {
+			return
+		}
 		var request URLCreationRequest
 
 		if err := ctx.BindJSON(&request); err != nil {
 			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
 			return
 		}
+		if request.UserId = requestOwner(ctx, request.UserId); request.UserId == "" || request.MaxClicks < 0 ||
+			request.RedirectStatus != 0 && !slices.Contains(commons.RedirectStatuses, request.RedirectStatus) {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
 
-		shortUrl := shortener.GenerateShortURL(request.LongURL, request.UserId)
-		store.SaveURLInRedis(shortUrl, request.LongURL)
+		if request.Alias != "" {
+			if err := shortener.ValidateAlias(request.Alias); err != nil {
+				ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.InvalidAlias))
+				return
+			}
+		}
+		if request.Generator == "" {
+			request.Generator = cfg.CodeGenerator
+		}
+		generator, ok := generators[request.Generator]
+		if !ok {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
+		now := time.Now().UTC()
+		expiresAt, err := expiration(cfg, request.ExpiresAt, request.TTL, now)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
+
+		link := &store.Link{
+			Code:           request.Alias,
+			LongURL:        request.LongURL,
+			UserId:         request.UserId,
+			CreatedAt:      now,
+			Metadata:       request.Metadata,
+			Tags:           request.Tags,
+			RedirectStatus: request.RedirectStatus,
+			ExpiresAt:      expiresAt,
+			MaxClicks:      request.MaxClicks,
+		}
+		if request.Alias != "" {
+			err = repo.Save(ctx.Request.Context(), link)
+		} else {
+			err = saveWithGeneratedCode(ctx, repo, generator, link)
+		}
+		if err != nil {
+			log.Printf("Failed CreateShortURL | Error: %v - shortURL: %s - originalURL: %s\n",
+				err, link.Code, request.LongURL)
+			abortWithStoreError(ctx, err)
+			return
+		}
+
+		path := link.Code
+		// the links of a workspace are served under its name, the same code can exist in other workspaces
+		if workspace := store.WorkspaceFrom(ctx.Request.Context()); workspace != store.DefaultWorkspace {
+			path = workspace + "/" + link.Code
+		}
 		ctx.JSON(http.StatusOK, gin.H{
 			"short_url": fmt.Sprintf("%s://%s:%d%s/%s/%s", cfg.Protocol,
 				cfg.Host,
 				cfg.Port,
 				cfg.Context,
 				commons.ShortenerPath,
-				shortUrl),
+				path),
 		})
 	}
 }
 
-func ReturnLongURL() gin.HandlerFunc {
+func ReturnLongURL(cfg *config.Config, repo store.LinkRepository) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if !authorize(ctx, auth.ScopeLinksRead) {
+			return
+		}
 		shortUrl := ctx.Request.URL.Query().Get("short_url")
-		initialUrl := store.RetrieveInitialURLFromRedis(shortUrl)
-		ctx.JSON(http.StatusOK, map[string]interface{}{
-			"short_url": shortUrl,
-			"long_url":  initialUrl,
+		link, ok := readableLink(ctx, repo, shortUrl)
+		if !ok {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, linkResponse(cfg, link))
+	}
+}
+
+// UpdateShortURL changes the destination, expiration or redirect status of a link owned by the requesting user
+func UpdateShortURL(cfg *config.Config, repo store.LinkRepository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !authorize(ctx, auth.ScopeLinksWrite) {
+			return
+		}
+		var request URLUpdateRequest
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
+		request.UserId = requestOwner(ctx, request.UserId)
+		if request.UserId == "" || request.LongURL != nil && *request.LongURL == "" || request.RedirectStatus != nil &&
+			*request.RedirectStatus != 0 && !slices.Contains(commons.RedirectStatuses, *request.RedirectStatus) {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
+
+		code := ctx.Param("code")
+		link, ok := ownedLink(ctx, repo, code, request.UserId)
+		if !ok {
+			return
+		}
+		if request.LongURL != nil {
+			link.LongURL = *request.LongURL
+		}
+		if request.Tags != nil {
+			link.Tags = *request.Tags
+		}
+		if request.RedirectStatus != nil {
+			link.RedirectStatus = *request.RedirectStatus
+		}
+		if request.ExpiresAt != nil || request.TTL != "" {
+			expiresAt, err := expiration(cfg, request.ExpiresAt, request.TTL, time.Now().UTC())
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+				return
+			}
+			link.ExpiresAt = expiresAt
+		}
+
+		if err := repo.Update(ctx.Request.Context(), link); err != nil {
+			log.Printf("Failed UpdateShortURL | Error: %v - shortURL: %s\n", err, code)
+			abortWithStoreError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, linkResponse(cfg, link))
+	}
+}
+
+// DeleteShortURL removes a link owned by the user given in the user_id query parameter
+func DeleteShortURL(repo store.LinkRepository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !authorize(ctx, auth.ScopeLinksWrite) {
+			return
+		}
+		userId := requestOwner(ctx, ctx.Query("user_id"))
+		if userId == "" {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
+
+		code := ctx.Param("code")
+		if _, ok := ownedLink(ctx, repo, code, userId); !ok {
+			return
+		}
+		if err := repo.Delete(ctx.Request.Context(), code); err != nil {
+			log.Printf("Failed DeleteShortURL | Error: %v - shortURL: %s\n", err, code)
+			abortWithStoreError(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// LinkStats returns the click statistics of a link, the query accepts the from and to days (YYYY-MM-DD, the last 30
+// days by default) and the interval of the time series (day or hour)
+func LinkStats(repo store.LinkRepository, clicks store.AnalyticsRepository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !authorize(ctx, auth.ScopeStatsRead) {
+			return
+		}
+		from, to, ok := dayRange(ctx)
+		if !ok {
+			return
+		}
+
+		code := ctx.Param("code")
+		if _, ok := readableLink(ctx, repo, code); !ok {
+			return
+		}
+
+		stats, err := analytics.LoadStats(ctx.Request.Context(), clicks, code, from, to,
+			ctx.DefaultQuery("interval", analytics.IntervalDay))
+		if stderrors.Is(err, analytics.ErrInvalidRange) {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
+		if err != nil {
+			log.Printf("Failed LinkStats | Error: %v - shortURL: %s\n", err, code)
+			abortWithStoreError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, stats)
+	}
+}
+
+// ExportClicks streams the click log of the link given by the code param, or of every link of the user given by the
+// id param, as CSV or JSON Lines. The query accepts format (csv or jsonl), from and to
+func ExportClicks(repo store.LinkRepository, clicks store.ClickLogRepository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !authorize(ctx, auth.ScopeStatsRead) {
+			return
+		}
+		from, to, ok := dayRange(ctx)
+		if !ok {
+			return
+		}
+		export, err := analytics.NewExport(ctx.DefaultQuery("format", analytics.FormatCSV), from, to)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return
+		}
+
+		subject := ctx.Param("code")
+		codes := []string{subject}
+		if subject != "" {
+			if _, ok := readableLink(ctx, repo, subject); !ok {
+				return
+			}
+		} else {
+			subject = ctx.Param("id")
+			if !actsFor(ctx, subject) {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewCustomError(errors.UserForbidden))
+				return
+			}
+			codes, err = userCodes(ctx, repo, subject)
+		}
+		if err != nil {
+			log.Printf("Failed ExportClicks | Error: %v - subject: %s\n", err, subject)
+			abortWithStoreError(ctx, err)
+			return
+		}
+
+		ctx.Header("Content-Type", export.ContentType())
+		ctx.Header("Content-Disposition",
+			mime.FormatMediaType("attachment", map[string]string{"filename": export.Filename(subject)}))
+		ctx.Status(http.StatusOK)
+		if err := export.Write(ctx.Request.Context(), clicks, codes, ctx.Writer); err != nil {
+			// the response has started, the client notices the truncated body
+			log.Printf("Failed ExportClicks | Error: %v - subject: %s\n", err, subject)
+			_ = ctx.Error(err)
+			ctx.Abort()
+		}
+	}
+}
+
+// userCodes returns the codes of the links of the user, sorted
+func userCodes(ctx *gin.Context, repo store.LinkRepository, userId string) ([]string, error) {
+	links, err := repo.List(ctx.Request.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, len(links))
+	for _, link := range links {
+		codes = append(codes, link.Code)
+	}
+	slices.Sort(codes)
+	return codes, nil
+}
+
+// dayRange reads the from and to query params (YYYY-MM-DD), the range defaults to the last 30 days. It answers
+// 400 and returns false when a day is malformed
+func dayRange(ctx *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now().UTC()
+	if value := ctx.Query("to"); value != "" {
+		var err error
+		if to, err = time.Parse(store.DayLayout, value); err != nil {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	from := to.AddDate(0, 0, -29)
+	if value := ctx.Query("from"); value != "" {
+		var err error
+		if from, err = time.Parse(store.DayLayout, value); err != nil {
+			ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	return from, to, true
+}
+
+// ListUserLinks returns a page of the links of a user, the query accepts sort (created or clicks), tag,
+// status (active or expired), domain, limit and the cursor returned as next_cursor by the previous page
+func ListUserLinks(cfg *config.Config, repo store.LinkRepository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !authorize(ctx, auth.ScopeLinksRead) {
+			return
+		}
+		if !actsFor(ctx, ctx.Param("id")) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewCustomError(errors.UserForbidden))
+			return
+		}
+		query := store.LinkQuery{
+			UserId: ctx.Param("id"),
+			Sort:   ctx.Query("sort"),
+			Tag:    ctx.Query("tag"),
+			Status: ctx.Query("status"),
+			Domain: ctx.Query("domain"),
+			Cursor: ctx.Query("cursor"),
+		}
+		if limit := ctx.Query("limit"); limit != "" {
+			var err error
+			if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit <= 0 {
+				ctx.JSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+				return
+			}
+		}
+
+		page, err := repo.Find(ctx.Request.Context(), query)
+		if err != nil {
+			log.Printf("Failed ListUserLinks | Error: %v - userId: %s\n", err, query.UserId)
+			abortWithStoreError(ctx, err)
+			return
+		}
+
+		links := make([]map[string]interface{}, 0, len(page.Links))
+		for _, link := range page.Links {
+			links = append(links, linkResponse(cfg, link))
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"links":       links,
+			"next_cursor": page.NextCursor,
 		})
 	}
 }
 
-func RedirectURL() gin.HandlerFunc {
+// ownedLink loads the link and aborts the request unless it belongs to userId
+func ownedLink(ctx *gin.Context, repo store.LinkRepository, code, userId string) (*store.Link, bool) {
+	link, err := repo.Get(ctx.Request.Context(), code)
+	if err != nil {
+		log.Printf("Failed to load link | Error: %v - shortURL: %s\n", err, code)
+		abortWithStoreError(ctx, err)
+		return nil, false
+	}
+	if link.UserId != userId {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewCustomError(errors.Forbidden))
+		return nil, false
+	}
+	return link, true
+}
+
+// readableLink loads the link and aborts the request unless the principal may read it
+func readableLink(ctx *gin.Context, repo store.LinkRepository, code string) (*store.Link, bool) {
+	link, err := repo.Get(ctx.Request.Context(), code)
+	if err != nil {
+		log.Printf("Failed to load link | Error: %v - shortURL: %s\n", err, code)
+		abortWithStoreError(ctx, err)
+		return nil, false
+	}
+	if !actsFor(ctx, link.UserId) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewCustomError(errors.Forbidden))
+		return nil, false
+	}
+	return link, true
+}
+
+// authorize aborts the request with 403 unless the role of the principal grants scope, always true when the API is open
+func authorize(ctx *gin.Context, scope string) bool {
+	if principal, ok := auth.FromContext(ctx); ok && !principal.HasScope(scope) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewCustomError(errors.InsufficientScope))
+		return false
+	}
+	return true
+}
+
+// requestOwner returns the user the request acts for: the owner of the API key, or the user sent by the client when
+// the API is open or the key is an admin key
+func requestOwner(ctx *gin.Context, requested string) string {
+	if principal, ok := auth.FromContext(ctx); ok && !principal.Admin() {
+		return principal.UserId
+	}
+	return requested
+}
+
+// actsFor reports whether the request may read or change the links of userId, always true when the API is open
+func actsFor(ctx *gin.Context, userId string) bool {
+	principal, ok := auth.FromContext(ctx)
+	return !ok || principal.Admin() || principal.UserId == userId
+}
+
+func linkResponse(cfg *config.Config, link *store.Link) map[string]interface{} {
+	return map[string]interface{}{
+		"short_url":        link.Code,
+		"long_url":         link.LongURL,
+		"created_at":       link.CreatedAt,
+		"tags":             link.Tags,
+		"redirect_status":  redirectStatus(cfg, link),
+		"expires_at":       link.ExpiresAt,
+		"expired":          link.Expired(time.Now()),
+		"max_clicks":       link.MaxClicks,
+		"clicks":           link.Clicks,
+		"remaining_clicks": link.RemainingClicks(),
+	}
+}
+
+// RedirectURL follows the short link of the s path parameter. When the code parameter is also present, s names the
+// workspace of the link and code is its code
+func RedirectURL(cfg *config.Config, repo store.LinkRepository, recorder *analytics.Recorder,
+	geo *geoip.Database) gin.HandlerFunc {
+	notFoundPage := loadNotFoundPage(cfg.NotFoundPage)
+	expiredPage, exhaustedPage := gonePage(errors.Gone), gonePage(errors.ClickLimitReached)
+	previewPage := []byte(defaultPreviewPage)
+
 	return func(ctx *gin.Context) {
 		shortUrl := ctx.Param("s")
-		initialUrl := store.RetrieveInitialURLFromRedis(shortUrl)
-		ctx.Redirect(http.StatusPermanentRedirect, initialUrl)
+		if code := ctx.Param("code"); code != "" {
+			if !store.ValidWorkspace(shortUrl) {
+				abortWithLinkError(ctx, http.StatusNotFound, errors.NotFound, notFoundPage)
+				return
+			}
+			ctx.Request = ctx.Request.WithContext(store.WithWorkspace(ctx.Request.Context(), shortUrl))
+			shortUrl = code
+		}
+		link, err := repo.Get(ctx.Request.Context(), shortUrl)
+		if stderrors.Is(err, store.ErrNotFound) {
+			abortWithLinkError(ctx, http.StatusNotFound, errors.NotFound, notFoundPage)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed RedirectURL | Error: %v - shortURL: %s\n", err, shortUrl)
+			abortWithStoreError(ctx, err)
+			return
+		}
+		if link.Expired(time.Now()) {
+			abortWithLinkError(ctx, http.StatusGone, errors.Gone, expiredPage)
+			return
+		}
+		agent := useragent.Parse(ctx.Request.UserAgent())
+		if agent.IsBot() && link.MaxClicks > 0 {
+			// the link previews of chat apps and crawlers would use up the clicks meant for people
+			if remaining := link.RemainingClicks(); *remaining == 0 {
+				abortWithLinkError(ctx, http.StatusGone, errors.ClickLimitReached, exhaustedPage)
+				return
+			}
+			ctx.Data(http.StatusOK, "text/html; charset=utf-8", previewPage)
+			return
+		}
+		if _, err := repo.Hit(ctx.Request.Context(), link); err != nil {
+			switch {
+			case stderrors.Is(err, store.ErrExhausted):
+				abortWithLinkError(ctx, http.StatusGone, errors.ClickLimitReached, exhaustedPage)
+				return
+			case stderrors.Is(err, store.ErrNotFound):
+				abortWithLinkError(ctx, http.StatusNotFound, errors.NotFound, notFoundPage)
+				return
+			case link.MaxClicks > 0:
+				// the limit cannot be enforced without the counter
+				log.Printf("Failed RedirectURL | Error: %v - shortURL: %s\n", err, shortUrl)
+				abortWithStoreError(ctx, err)
+				return
+			default:
+				log.Printf("Failed to count click | Error: %v - shortURL: %s\n", err, shortUrl)
+			}
+		}
+
+		ctx.Set(commons.AgentContextKey, agent)
+		// the lookup reads the local database, the redirect never waits for the network
+		location := geo.Lookup(ctx.ClientIP())
+		trace.SpanFromContext(ctx.Request.Context()).SetAttributes(
+			attribute.String("user_agent.browser", agent.Browser),
+			attribute.String("user_agent.os", agent.OS),
+			attribute.String("user_agent.device", agent.Device),
+			attribute.String("user_agent.class", agent.Class),
+			attribute.Bool("user_agent.bot", agent.IsBot()),
+			attribute.String("client.geo.country", location.Country),
+			attribute.String("client.geo.region", location.Region),
+			attribute.String("client.geo.city", location.City),
+		)
+		recorder.Record(link.Code, ctx.Request, ctx.ClientIP(), agent, location)
+		ctx.Redirect(redirectStatus(cfg, link), link.LongURL)
+	}
+}
+
+// expiration resolves the requested expiration time, the configured default applies when neither expiresAt nor ttl
+// is given. Nil means the link never expires
+func expiration(cfg *config.Config, requestedAt *time.Time, requestedTTL string, now time.Time) (*time.Time, error) {
+	if requestedAt != nil && requestedTTL != "" {
+		return nil, fmt.Errorf("expires_at and ttl are exclusive")
+	}
+	if requestedAt != nil {
+		if !requestedAt.After(now) {
+			return nil, fmt.Errorf("expires_at is in the past: %s", requestedAt)
+		}
+		expiresAt := requestedAt.UTC()
+		return &expiresAt, nil
+	}
+
+	ttl := cfg.LinkTTL
+	switch requestedTTL {
+	case "":
+	case "never":
+		ttl = 0
+	default:
+		var err error
+		if ttl, err = time.ParseDuration(requestedTTL); err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid ttl: %s", requestedTTL)
+		}
+	}
+	if ttl == 0 {
+		return nil, nil
+	}
+	expiresAt := now.Add(ttl)
+	return &expiresAt, nil
+}
+
+// redirectStatus returns the status stored with the link or the configured default
+func redirectStatus(cfg *config.Config, link *store.Link) int {
+	if link.RedirectStatus != 0 {
+		return link.RedirectStatus
+	}
+	return cfg.RedirectStatus
+}
+
+// saveWithGeneratedCode reserves a generated code for the link, a code taken by another link is never overwritten,
+// the next candidate is tried instead. Shortening the same URL twice for the same user with the same settings reuses
+// the existing code while it can still be followed
+func saveWithGeneratedCode(ctx *gin.Context, repo store.LinkRepository, generator shortener.Generator, link *store.Link) error {
+	for attempt := 0; attempt < shortener.MaxAttempts; attempt++ {
+		code, err := generator.Generate(ctx.Request.Context(), shortener.Request{
+			LongURL: link.LongURL,
+			UserId:  link.UserId,
+			Attempt: attempt,
+		})
+		if err != nil {
+			return err
+		}
+
+		link.Code = code
+		err = repo.Save(ctx.Request.Context(), link)
+		if !stderrors.Is(err, store.ErrConflict) {
+			return err
+		}
+
+		existing, err := repo.Get(ctx.Request.Context(), link.Code)
+		if err != nil && !stderrors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		if existing != nil && reusable(existing, link, time.Now()) {
+			return nil
+		}
+	}
+	return store.ErrConflict
+}
+
+// reusable reports whether existing can be answered for the requested link: it shortens the same URL for the same user
+// with the same settings and can still be followed
+func reusable(existing, requested *store.Link, now time.Time) bool {
+	sameExpiration := existing.ExpiresAt == nil && requested.ExpiresAt == nil ||
+		existing.ExpiresAt != nil && requested.ExpiresAt != nil && existing.ExpiresAt.Equal(*requested.ExpiresAt)
+	remaining := existing.RemainingClicks()
+	return existing.LongURL == requested.LongURL && existing.UserId == requested.UserId && sameExpiration &&
+		existing.MaxClicks == requested.MaxClicks && existing.RedirectStatus == requested.RedirectStatus &&
+		slices.Equal(existing.Tags, requested.Tags) && !existing.Expired(now) && (remaining == nil || *remaining > 0)
+}
+
+// abortWithStoreError maps the typed storage errors to their HTTP status
+func abortWithStoreError(ctx *gin.Context, err error) {
+	switch {
+	case stderrors.Is(err, store.ErrNotFound):
+		ctx.AbortWithStatusJSON(http.StatusNotFound, errors.NewCustomError(errors.NotFound))
+	case stderrors.Is(err, store.ErrInvalidQuery):
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, errors.NewCustomError(errors.BadRequest))
+	case stderrors.Is(err, store.ErrConflict):
+		ctx.AbortWithStatusJSON(http.StatusConflict, errors.NewCustomError(errors.Conflict))
+	case stderrors.Is(err, store.ErrUnavailable):
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, errors.NewCustomError(errors.ServiceUnavailable))
+	default:
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errors.NewCustomError(errors.InternalServerError))
 	}
 }
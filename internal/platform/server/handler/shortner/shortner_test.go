@@ -0,0 +1,900 @@
+package shortner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/config"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/analytics"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/middleware"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/shortener"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// browserUserAgent is sent by the tests following links with a click limit, which are not counted for bots
+const browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36"
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		Protocol:       "http",
+		Host:           "localhost",
+		Port:           8080,
+		RedirectStatus: http.StatusPermanentRedirect,
+		CodeGenerator:  shortener.StrategyHash,
+		CodeLength:     8,
+	}
+}
+
+func newTestRouter(repo store.LinkRepository) *gin.Engine {
+	return newTestRouterWithConfig(newTestConfig(), repo)
+}
+
+func newTestRouterWithConfig(cfg *config.Config, repo store.LinkRepository) *gin.Engine {
+	generators, err := shortener.NewGenerators(cfg, repo)
+	if err != nil {
+		panic(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/url", CreateShortURL(cfg, repo, generators))
+	r.GET("/url", ReturnLongURL(cfg, repo))
+	r.PATCH("/url/:code", UpdateShortURL(cfg, repo))
+	r.DELETE("/url/:code", DeleteShortURL(repo))
+	r.GET("/r/:s", RedirectURL(cfg, repo, nil, nil))
+	r.GET("/users/:id/links", ListUserLinks(cfg, repo))
+	return r
+}
+
+func TestCreateShortURLStoresLink(t *testing.T) {
+	repo := store.NewMemoryStore()
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(`{"long_url":"https://example.com","user_id":"1"}`))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	links, err := repo.List(context.Background(), "1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "https://example.com", links[0].LongURL)
+	assert.Contains(t, w.Body.String(), "/r/"+links[0].Code)
+}
+
+func newTestRouterAs(principal *auth.Principal, repo store.LinkRepository) *gin.Engine {
+	cfg := newTestConfig()
+	generators, err := shortener.NewGenerators(cfg, repo)
+	if err != nil {
+		panic(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) { auth.SetPrincipal(ctx, principal) })
+	r.POST("/url", CreateShortURL(cfg, repo, generators))
+	r.GET("/url", ReturnLongURL(cfg, repo))
+	r.PATCH("/url/:code", UpdateShortURL(cfg, repo))
+	r.DELETE("/url/:code", DeleteShortURL(repo))
+	r.GET("/users/:id/links", ListUserLinks(cfg, repo))
+	return r
+}
+
+func TestWorkspacesIsolateLinksWithTheSameCode(t *testing.T) {
+	cfg := newTestConfig()
+	repo := store.NewMemoryStore()
+	generators, err := shortener.NewGenerators(cfg, repo)
+	require.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/url", middleware.Workspace(), CreateShortURL(cfg, repo, generators))
+	r.GET("/url", middleware.Workspace(), ReturnLongURL(cfg, repo))
+	r.GET("/r/:s", RedirectURL(cfg, repo, nil, nil))
+	r.GET("/r/:s/:code", RedirectURL(cfg, repo, nil, nil))
+	serve := func(req *http.Request, workspace string) *httptest.ResponseRecorder {
+		if workspace != "" {
+			req.Header.Set("X-Workspace", workspace)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://eng.example.com","user_id":"1","alias":"promo"}`)), "eng")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/r/eng/promo")
+	assert.Equal(t, http.StatusNotFound, serve(httptest.NewRequest(http.MethodGet, "/url?short_url=promo", nil), "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(httptest.NewRequest(http.MethodGet, "/r/promo", nil), "").Code)
+
+	// another workspace can take the same alias without touching the first link
+	w = serve(httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"1","alias":"promo"}`)), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/r/promo")
+
+	w = serve(httptest.NewRequest(http.MethodGet, "/r/eng/promo", nil), "")
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://eng.example.com", w.Header().Get("Location"))
+	w = serve(httptest.NewRequest(http.MethodGet, "/r/promo", nil), "")
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+	assert.Equal(t, http.StatusNotFound, serve(httptest.NewRequest(http.MethodGet, "/r/sales/promo", nil), "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(httptest.NewRequest(http.MethodGet, "/r/Eng/promo", nil), "").Code)
+}
+
+func TestHandlersEnforceTheRoleOfThePrincipal(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(), &store.Link{
+		Code: "mine01", LongURL: "https://example.com", UserId: "1",
+	}))
+	r := newTestRouterAs(&auth.Principal{UserId: "1", Scopes: auth.Roles[auth.RoleViewer]}, repo)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/url?short_url=mine01", nil),
+		httptest.NewRequest(http.MethodGet, "/users/1/links", nil),
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, req.URL.String())
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(`{"long_url":"https://example.org"}`)),
+		httptest.NewRequest(http.MethodPatch, "/url/mine01", strings.NewReader(`{"long_url":"https://example.org"}`)),
+		httptest.NewRequest(http.MethodDelete, "/url/mine01", nil),
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, req.Method)
+		assert.Contains(t, w.Body.String(), "scope")
+	}
+
+	r = newTestRouterAs(&auth.Principal{UserId: "1", Scopes: auth.Roles[auth.RoleEditor]}, repo)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/url/mine01", strings.NewReader(`{"long_url":"https://example.org"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/url/mine01", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestHandlersTakeTheOwnerFromTheAPIKey(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(), &store.Link{
+		Code: "other1", LongURL: "https://example.org", UserId: "2",
+	}))
+	r := newTestRouterAs(&auth.Principal{UserId: "1", Scopes: auth.Roles[auth.RoleEditor]}, repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"2"}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	links, err := repo.List(context.Background(), "1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/url?short_url=other1", nil),
+		httptest.NewRequest(http.MethodDelete, "/url/other1?user_id=2", nil),
+		httptest.NewRequest(http.MethodGet, "/users/2/links", nil),
+	} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, req.URL.String())
+	}
+
+	// admins act for any user
+	r = newTestRouterAs(&auth.Principal{Scopes: []string{auth.ScopeAdmin}}, repo)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url?short_url=other1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUserEndpointsRejectAnotherUser(t *testing.T) {
+	repo := store.NewMemoryStore()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		auth.SetPrincipal(ctx, &auth.Principal{UserId: "1", Scopes: auth.Roles[auth.RoleViewer]})
+	})
+	r.GET("/users/:id/links", ListUserLinks(newTestConfig(), repo))
+	r.GET("/users/:id/clicks/export", ExportClicks(repo, repo))
+
+	for _, path := range []string{"/users/2/links", "/users/2/clicks/export"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+		assert.Contains(t, w.Body.String(), errors.GetErrorMessage(errors.UserForbidden), path)
+	}
+}
+
+func TestCreateShortURLRejectsInvalidBody(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(`{"user_id":"1"}`))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRedirectURLRedirectsToLongURL(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(), &store.Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/abc123", nil))
+
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+}
+
+func TestReturnLongURL(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(), &store.Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url?short_url=abc123", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"short_url":"abc123","long_url":"https://example.com","redirect_status":308,"expires_at":null,"expired":false,
+		"max_clicks":0,"clicks":0,"remaining_clicks":null,"created_at":"0001-01-01T00:00:00Z","tags":null}`,
+		w.Body.String())
+}
+
+// unavailableRepository simulates a storage backend that cannot be reached
+type unavailableRepository struct {
+	store.LinkRepository
+}
+
+func (unavailableRepository) Save(context.Context, *store.Link) error {
+	return store.ErrUnavailable
+}
+
+func (unavailableRepository) Get(context.Context, string) (*store.Link, error) {
+	return nil, store.ErrUnavailable
+}
+
+func TestReturnLongURLReturnsNotFoundForUnknownCode(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url?short_url=missing", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"message":"link not found","code":-2999}`, w.Body.String())
+}
+
+func TestHandlersReturnServiceUnavailableWhenStorageIsDown(t *testing.T) {
+	r := newTestRouter(unavailableRepository{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(`{"long_url":"https://example.com","user_id":"1"}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url?short_url=abc123", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/abc123", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestRedirectURLReturnsJSONNotFoundForAPIClients(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/r/missing", nil)
+	req.Header.Set("Accept", "application/json")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+}
+
+func TestRedirectURLReturnsHTMLNotFoundForBrowsers(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "404.html")
+	require.NoError(t, os.WriteFile(page, []byte("<h1>custom page</h1>"), 0600))
+	cfg := newTestConfig()
+	cfg.NotFoundPage = page
+	r := newTestRouterWithConfig(cfg, store.NewMemoryStore())
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/r/missing", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Equal(t, "<h1>custom page</h1>", w.Body.String())
+}
+
+func TestRedirectURLUsesLinkRedirectStatus(t *testing.T) {
+	repo := store.NewMemoryStore()
+	cfg := newTestConfig()
+	cfg.RedirectStatus = http.StatusMovedPermanently
+	r := newTestRouterWithConfig(cfg, repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com/campaign","user_id":"1","redirect_status":302}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, repo.Save(context.Background(), &store.Link{Code: "docs", LongURL: "https://example.com/docs", UserId: "1"}))
+
+	links, err := repo.List(context.Background(), "1")
+	require.NoError(t, err)
+	for _, link := range links {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/"+link.Code, nil))
+		if link.Code == "docs" {
+			assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		} else {
+			assert.Equal(t, http.StatusFound, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url?short_url=docs", nil))
+	assert.Contains(t, w.Body.String(), `"redirect_status":301`)
+}
+
+func TestCreateShortURLRejectsUnsupportedRedirectStatus(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"1","redirect_status":303}`)))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateShortURLWithAlias(t *testing.T) {
+	repo := store.NewMemoryStore()
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"1","alias":"my-link"}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/r/my-link")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/my-link", nil))
+	assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+}
+
+func TestCreateShortURLReturnsConflictWhenAliasIsTaken(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+	body := `{"long_url":"https://example.com","user_id":"1","alias":"my-link"}`
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestCreateShortURLRejectsInvalidAliases(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	for _, alias := range []string{"health", "url", "r", "a b", "x"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+			strings.NewReader(`{"long_url":"https://example.com","user_id":"1","alias":"`+alias+`"}`)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, alias)
+	}
+}
+
+func TestCreateShortURLIsIdempotentForTheSameURL(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+	body := `{"long_url":"https://example.com","user_id":"1"}`
+
+	first := httptest.NewRecorder()
+	r.ServeHTTP(first, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body)))
+	second := httptest.NewRecorder()
+	r.ServeHTTP(second, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+}
+
+func TestCreateShortURLDoesNotReuseALinkWithOtherSettings(t *testing.T) {
+	repo := store.NewMemoryStore()
+	r := newTestRouter(repo)
+	create := func(body string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response["short_url"][strings.LastIndex(response["short_url"], "/")+1:]
+	}
+	follow := func(code string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/r/"+code, nil)
+		req.Header.Set("User-Agent", browserUserAgent)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	plain := create(`{"long_url":"https://example.com/reset","user_id":"1"}`)
+	oneTime := create(`{"long_url":"https://example.com/reset","user_id":"1","max_clicks":1}`)
+	assert.NotEqual(t, plain, oneTime)
+	assert.NotEqual(t, plain, create(`{"long_url":"https://example.com/reset","user_id":"1","redirect_status":302}`))
+
+	require.Equal(t, http.StatusPermanentRedirect, follow(oneTime))
+	require.Equal(t, http.StatusGone, follow(oneTime))
+
+	// the used up link is not answered again, the new one can be followed
+	again := create(`{"long_url":"https://example.com/reset","user_id":"1","max_clicks":1}`)
+	assert.NotEqual(t, oneTime, again)
+	assert.Equal(t, http.StatusPermanentRedirect, follow(again))
+}
+
+func TestCreateShortURLNeverOverwritesACollidingCode(t *testing.T) {
+	repo := store.NewMemoryStore()
+	ctx := context.Background()
+	colliding := shortener.GenerateShortURL("https://example.com", "1")
+	require.NoError(t, repo.Save(ctx, &store.Link{Code: colliding, LongURL: "https://other.example.com", UserId: "2"}))
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"1"}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	retried := shortener.GenerateCandidate("https://example.com", "1", 1)
+	assert.Contains(t, w.Body.String(), "/r/"+retried)
+
+	original, err := repo.Get(ctx, colliding)
+	require.NoError(t, err)
+	assert.Equal(t, "https://other.example.com", original.LongURL)
+
+	// shortening the same URL again finds it on the retried code
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"1"}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/r/"+retried)
+}
+
+func TestCreateShortURLReturnsConflictWhenEveryCandidateCollides(t *testing.T) {
+	repo := store.NewMemoryStore()
+	ctx := context.Background()
+	for attempt := 0; attempt < shortener.MaxAttempts; attempt++ {
+		code := shortener.GenerateCandidate("https://example.com", "1", attempt)
+		require.NoError(t, repo.Save(ctx, &store.Link{Code: code, LongURL: "https://other.example.com", UserId: "2"}))
+	}
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"1"}`)))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestCreateShortURLWithRequestedGenerator(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	for _, expected := range []string{"1", "2"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+			strings.NewReader(`{"long_url":"https://example.com/print","user_id":"1","generator":"counter"}`)))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, strings.HasSuffix(w.Body.String(), `/r/`+expected+`"}`), w.Body.String())
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"1","generator":"unknown"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateShortURLWithExpiration(t *testing.T) {
+	repo := store.NewMemoryStore()
+	r := newTestRouter(repo)
+
+	for _, body := range []string{
+		`{"long_url":"https://example.com/ttl","user_id":"1","ttl":"72h","alias":"with-ttl"}`,
+		`{"long_url":"https://example.com/never","user_id":"1","ttl":"never","alias":"never"}`,
+		`{"long_url":"https://example.com/at","user_id":"1","expires_at":"2999-01-01T00:00:00Z","alias":"with-date"}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code, body)
+	}
+
+	ctx := context.Background()
+	withTTL, err := repo.Get(ctx, "with-ttl")
+	require.NoError(t, err)
+	require.NotNil(t, withTTL.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(72*time.Hour), *withTTL.ExpiresAt, time.Minute)
+
+	never, err := repo.Get(ctx, "never")
+	require.NoError(t, err)
+	assert.Nil(t, never.ExpiresAt)
+
+	withDate, err := repo.Get(ctx, "with-date")
+	require.NoError(t, err)
+	assert.Equal(t, 2999, withDate.ExpiresAt.Year())
+}
+
+func TestCreateShortURLRejectsInvalidExpiration(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	for _, body := range []string{
+		`{"long_url":"https://example.com","user_id":"1","ttl":"tomorrow"}`,
+		`{"long_url":"https://example.com","user_id":"1","ttl":"-1h"}`,
+		`{"long_url":"https://example.com","user_id":"1","expires_at":"2000-01-01T00:00:00Z"}`,
+		`{"long_url":"https://example.com","user_id":"1","ttl":"1h","expires_at":"2999-01-01T00:00:00Z"}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestRedirectURLReturnsGoneForExpiredLinks(t *testing.T) {
+	repo := store.NewMemoryStore()
+	expiresAt := time.Now().Add(-time.Minute)
+	require.NoError(t, repo.Save(context.Background(), &store.Link{
+		Code: "expired", LongURL: "https://example.com", UserId: "1", ExpiresAt: &expiresAt,
+	}))
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/expired", nil))
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	// browsers are told the link expired, not that it does not exist
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/r/expired", nil)
+	req.Header.Set("Accept", "text/html")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), "link has expired")
+	assert.NotContains(t, w.Body.String(), "not found")
+
+	// the metadata is still available for reporting
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url?short_url=expired", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"expired":true`)
+}
+
+func TestRedirectURLReturnsGoneAfterMaxClicks(t *testing.T) {
+	repo := store.NewMemoryStore()
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"1","alias":"one-time","max_clicks":2}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/r/one-time", nil)
+		req.Header.Set("User-Agent", browserUserAgent)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url?short_url=one-time", nil))
+	assert.Contains(t, w.Body.String(), `"remaining_clicks":0`)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/one-time", nil))
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/r/one-time", nil)
+	req.Header.Set("Accept", "text/html")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), "link reached its click limit")
+}
+
+func TestRedirectURLDoesNotCountBotsOnLinksWithAClickLimit(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(),
+		&store.Link{Code: "one-time", LongURL: "https://example.com", UserId: "1", MaxClicks: 1}))
+	r := newTestRouter(repo)
+	follow := func(userAgent string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/r/one-time", nil)
+		req.Header.Set("User-Agent", userAgent)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	bot := "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"
+	// the preview neither redirects nor uses the only click of the link
+	w := follow(bot)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.NotContains(t, w.Body.String(), "https://example.com")
+
+	w = follow(browserUserAgent)
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+
+	assert.Equal(t, http.StatusGone, follow(bot).Code)
+	assert.Equal(t, http.StatusGone, follow(browserUserAgent).Code)
+}
+
+func TestCreateShortURLRejectsNegativeMaxClicks(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url",
+		strings.NewReader(`{"long_url":"https://example.com","user_id":"1","max_clicks":-1}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateShortURLChangesDestinationExpiryAndRedirectStatus(t *testing.T) {
+	repo := store.NewMemoryStore()
+	expiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, repo.Save(context.Background(), &store.Link{
+		Code: "abc123", LongURL: "https://example.com", UserId: "1", ExpiresAt: &expiresAt,
+	}))
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/url/abc123",
+		strings.NewReader(`{"user_id":"1","long_url":"https://example.org","redirect_status":302,"ttl":"never"}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"long_url":"https://example.org"`)
+
+	got, err := repo.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org", got.LongURL)
+	assert.Equal(t, http.StatusFound, got.RedirectStatus)
+	assert.Nil(t, got.ExpiresAt)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/abc123", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.org", w.Header().Get("Location"))
+}
+
+func TestUpdateShortURLRejectsInvalidChanges(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(), &store.Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+	r := newTestRouter(repo)
+
+	for _, body := range []string{
+		`{"long_url":"https://example.org"}`,
+		`{"user_id":"1","long_url":""}`,
+		`{"user_id":"1","redirect_status":303}`,
+		`{"user_id":"1","ttl":"soon"}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/url/abc123", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestUpdateAndDeleteAreRestrictedToTheOwner(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(), &store.Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/url/abc123",
+		strings.NewReader(`{"user_id":"2","long_url":"https://evil.example"}`)))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/url/abc123?user_id=2", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	got, err := repo.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", got.LongURL)
+}
+
+func TestDeleteShortURL(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(), &store.Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+	r := newTestRouter(repo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/url/abc123?user_id=1", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err := repo.Get(context.Background(), "abc123")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/url/abc123?user_id=1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListUserLinksPaginatesAndFilters(t *testing.T) {
+	repo := store.NewMemoryStore()
+	r := newTestRouter(repo)
+	for i, body := range []string{
+		`{"long_url":"https://example.com/a","user_id":"1","alias":"first","tags":["docs"]}`,
+		`{"long_url":"https://example.org/b","user_id":"1","alias":"second"}`,
+		`{"long_url":"https://example.com/c","user_id":"1","alias":"third","tags":["docs"]}`,
+		`{"long_url":"https://example.com/d","user_id":"2","alias":"foreign","tags":["docs"]}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code, i)
+		// keeps the creation times distinct
+		time.Sleep(time.Millisecond)
+	}
+
+	var page struct {
+		Links []struct {
+			ShortURL string `json:"short_url"`
+		} `json:"links"`
+		NextCursor string `json:"next_cursor"`
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/links?tag=docs&limit=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Links, 1)
+	assert.Equal(t, "third", page.Links[0].ShortURL)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/links?tag=docs&limit=1&cursor="+page.NextCursor, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Links, 1)
+	assert.Equal(t, "first", page.Links[0].ShortURL)
+	assert.Empty(t, page.NextCursor)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/links?domain=example.org", nil))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Links, 1)
+	assert.Equal(t, "second", page.Links[0].ShortURL)
+}
+
+func TestListUserLinksRejectsInvalidQueries(t *testing.T) {
+	r := newTestRouter(store.NewMemoryStore())
+
+	for _, query := range []string{"limit=0", "limit=x", "limit=1000", "sort=name", "status=gone", "cursor=%25"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/links?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestRedirectURLRecordsClicks(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(), &store.Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+	cfg := newTestConfig()
+	cfg.AnalyticsBuffer = 16
+	cfg.AnalyticsFlushInterval = time.Hour
+	cfg.AnalyticsSalt = "salt"
+	recorder := analytics.NewRecorder(cfg, repo)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/r/:s", RedirectURL(cfg, repo, recorder, nil))
+	for _, userAgent := range []string{
+		browserUserAgent,
+		browserUserAgent,
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/r/abc123", nil)
+		req.Header.Set("User-Agent", userAgent)
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusPermanentRedirect, w.Code)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/missing", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.NoError(t, recorder.Close())
+
+	counters, err := repo.Counters(context.Background(), "abc123", time.Now(), time.Now())
+	require.NoError(t, err)
+	require.Len(t, counters, 1)
+	assert.Equal(t, int64(3), counters[0].Counters["total"])
+	assert.Equal(t, int64(2), counters[0].Counters["class:human"])
+	assert.Equal(t, int64(2), counters[0].Counters["browser:Chrome"])
+	assert.Equal(t, int64(1), counters[0].Counters["bot:Slack"])
+	missing, err := repo.Counters(context.Background(), "missing", time.Now(), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+}
+
+func TestLinkStats(t *testing.T) {
+	repo := store.NewMemoryStore()
+	require.NoError(t, repo.Save(context.Background(), &store.Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.IncrementCounters(context.Background(), "abc123", day, map[string]int64{"total": 2, "hour:09": 2}))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/url/:code/stats", LinkStats(repo, repo))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url/abc123/stats?from=2025-03-09&to=2025-03-10", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats analytics.Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, int64(2), stats.TotalClicks)
+	assert.Len(t, stats.Series, 2)
+
+	for _, query := range []string{"from=yesterday", "from=2025-03-10&to=2025-03-01", "interval=week", "from=2020-01-01"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url/abc123/stats?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url/missing/stats", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestExportClicks(t *testing.T) {
+	repo := store.NewMemoryStore()
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, &store.Link{Code: "first", LongURL: "https://example.com/1", UserId: "1"}))
+	require.NoError(t, repo.Save(ctx, &store.Link{Code: "second", LongURL: "https://example.com/2", UserId: "1"}))
+	day := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.AppendClicks(ctx, []store.ClickRecord{
+		{Code: "first", Time: day, Data: json.RawMessage(`{"code":"first","time":"2025-03-10T09:00:00Z","browser":"Firefox"}`)},
+		{Code: "second", Time: day, Data: json.RawMessage(`{"code":"second","time":"2025-03-10T09:00:00Z"}`)},
+	}))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/url/:code/clicks/export", ExportClicks(repo, repo))
+	r.GET("/users/:id/clicks/export", ExportClicks(repo, repo))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url/first/clicks/export?from=2025-03-10&to=2025-03-10", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=clicks-first-2025-03-10-2025-03-10.csv`, w.Header().Get("Content-Disposition"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[1], "first,2025-03-10T09:00:00Z,"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/clicks/export?format=jsonl&from=2025-03-01&to=2025-03-31", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines = strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"code":"first"`)
+	assert.Contains(t, lines[1], `"code":"second"`)
+
+	for _, query := range []string{"format=xml", "from=yesterday", "from=2025-03-10&to=2025-03-01"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url/first/clicks/export?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), query)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/url/missing/clicks/export", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -5,17 +5,25 @@ import (
 	"fmt"
 	"github.com/alexperezortuno/go-url-shortner/internal/commons"
 	"github.com/alexperezortuno/go-url-shortner/internal/config"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/analytics"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/auth"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/geoip"
 	"github.com/alexperezortuno/go-url-shortner/internal/platform/middleware"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/ratelimit"
 	"github.com/alexperezortuno/go-url-shortner/internal/platform/server/handler/health"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/server/handler/keys"
 	"github.com/alexperezortuno/go-url-shortner/internal/platform/server/handler/shortner"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/shortener"
 	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/store"
 	"github.com/alexperezortuno/go-url-shortner/internal/platform/tracing"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"time"
 )
 
@@ -23,10 +31,23 @@ type Server struct {
 	httpAddr        string
 	engine          *gin.Engine
 	shutdownTimeout time.Duration
+	links           store.Repository
+	generators      map[string]shortener.Generator
+	recorder        *analytics.Recorder
+	geo             *geoip.Database
+	// authenticate is nil when the management API is open
+	authenticate gin.HandlerFunc
+	// limiter is nil when the requests are not rate limited
+	limiter ratelimit.Limiter
 }
 
-func New(ctx context.Context, cfg *config.Config) (context.Context, Server) {
-	cfg.SetGinMode()
+func New(ctx context.Context, cfg *config.Config, links store.Repository) (context.Context, Server) {
+	if err := cfg.SetGinMode(); err != nil {
+		log.Fatalf("Invalid release: %v", err)
+	}
+	if !slices.Contains(commons.RedirectStatuses, cfg.RedirectStatus) {
+		log.Fatalf("Invalid redirect status: %d", cfg.RedirectStatus)
+	}
 
 	if cfg.TracingEnabled {
 		// Initialize tracing
@@ -41,16 +62,79 @@ func New(ctx context.Context, cfg *config.Config) (context.Context, Server) {
 		}()
 	}
 
+	generators, err := shortener.NewGenerators(cfg, links)
+	if err != nil {
+		log.Fatalf("Invalid code generator configuration: %v", err)
+	}
+
 	srv := Server{
 		engine:          gin.New(),
 		httpAddr:        fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 		shutdownTimeout: cfg.ShutdownTimeout,
+		links:           links,
+		generators:      generators,
+	}
+	// the client IP the redirects are limited by is only read from X-Forwarded-For when a trusted proxy sent it
+	if err := srv.engine.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	if cfg.AnalyticsEnabled {
+		// a zero buffer would drop almost every click and the recorder can not flush without an interval
+		if cfg.AnalyticsBuffer <= 0 {
+			log.Fatalf("Invalid analytics buffer: %d", cfg.AnalyticsBuffer)
+		}
+		if cfg.AnalyticsFlushInterval <= 0 {
+			log.Fatalf("Invalid analytics flush interval: %s", cfg.AnalyticsFlushInterval)
+		}
+		srv.recorder = analytics.NewRecorder(cfg, links)
+	}
+	srv.geo = geoip.Load(cfg.GeoIPDatabase)
+	if cfg.AuthEnabled {
+		if cfg.AuthAdminKey == "" && cfg.JWTKeySet == "" {
+			log.Fatalf("AUTH_ENABLED requires AUTH_ADMIN_KEY or JWT_JWKS, no request could be authenticated")
+		}
+		if cfg.AuthAdminKey == "" {
+			log.Printf("AUTH_ADMIN_KEY is not set, API keys can only be issued with an existing admin key")
+		}
+		srv.authenticate = middleware.Authenticate(links, cfg.AuthAdminKey, tokenVerifier(ctx, cfg))
+	} else {
+		log.Printf("AUTH_ENABLED is false, the management API is open to anyone")
+	}
+
+	if cfg.RateLimitEnabled {
+		srv.limiter, err = ratelimit.New(cfg)
+		if err != nil {
+			log.Fatalf("Invalid rate limit configuration: %v", err)
+		}
 	}
-	store.InitializeStore(cfg)
 
 	log.Printf("Check app in %s:%d%s/%s", cfg.Host, cfg.Port, cfg.Context, "health")
 	srv.registerRoutes(cfg)
-	return serverContext(ctx), srv
+	ctx = serverContext(ctx)
+	// the backends other than Redis drop the expired links and the old analytics themselves
+	go store.Sweep(ctx, links, store.PurgeInterval)
+	return ctx, srv
+}
+
+// tokenVerifier returns the verifier of the JWTs issued by the identity provider, nil when JWT_JWKS is not set
+func tokenVerifier(ctx context.Context, cfg *config.Config) *auth.TokenVerifier {
+	if cfg.JWTKeySet == "" {
+		return nil
+	}
+	if cfg.JWTIssuer == "" || cfg.JWTAudience == "" {
+		log.Fatalf("JWT_ISSUER and JWT_AUDIENCE are required to accept JWTs")
+	}
+	keySet, err := auth.LoadKeySet(ctx, cfg.JWTKeySet)
+	if err != nil {
+		log.Fatalf("failed to load the JWT key set: %v", err)
+	}
+	return auth.NewTokenVerifier(keySet, auth.TokenOptions{
+		Issuer:         cfg.JWTIssuer,
+		Audience:       cfg.JWTAudience,
+		OwnerClaim:     cfg.JWTOwnerClaim,
+		RolesClaim:     cfg.JWTRolesClaim,
+		WorkspaceClaim: cfg.JWTWorkspaceClaim,
+	})
 }
 
 func (s *Server) Run(ctx context.Context) error {
@@ -70,7 +154,14 @@ func (s *Server) Run(ctx context.Context) error {
 	ctxShutDown, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
-	return srv.Shutdown(ctxShutDown)
+	err := srv.Shutdown(ctxShutDown)
+	// the clicks queued by the last redirects are stored before exiting
+	_ = s.recorder.Close()
+	_ = s.geo.Close()
+	if closer, ok := s.limiter.(io.Closer); ok {
+		_ = closer.Close()
+	}
+	return err
 }
 
 func (s *Server) registerRoutes(cfg *config.Config) {
@@ -92,11 +183,51 @@ func (s *Server) registerRoutes(cfg *config.Config) {
 		s.engine.Use(middleware.TracingMiddleware())
 	}
 
+	// the creations and the redirects are limited apart, the redirects per client IP
+	createLimit := middleware.RateLimit(s.limiter, "create",
+		ratelimit.Limit{Requests: cfg.CreateRateLimit, Window: cfg.CreateRateWindow})
+	redirectLimit := middleware.RateLimit(s.limiter, "redirect",
+		ratelimit.Limit{Requests: cfg.RedirectRateLimit, Window: cfg.RedirectRateWindow})
+
 	// Routes
 	s.engine.GET(fmt.Sprintf("%s/%s", ctx, commons.HealthPath), health.CheckHandler())
-	s.engine.POST(fmt.Sprintf("%s/%s", ctx, commons.UrlPath), shortner.CreateShortURL(cfg))
-	s.engine.GET(fmt.Sprintf("%s/%s", ctx, commons.UrlPath), shortner.ReturnLongURL())
-	s.engine.GET(fmt.Sprintf("%s/%s/:s", ctx, commons.ShortenerPath), shortner.RedirectURL())
+	s.engine.POST(fmt.Sprintf("%s/%s", ctx, commons.UrlPath),
+		s.guard(createLimit, shortner.CreateShortURL(cfg, s.links, s.generators))...)
+	s.engine.GET(fmt.Sprintf("%s/%s", ctx, commons.UrlPath),
+		s.guard(shortner.ReturnLongURL(cfg, s.links))...)
+	s.engine.PATCH(fmt.Sprintf("%s/%s/:code", ctx, commons.UrlPath),
+		s.guard(shortner.UpdateShortURL(cfg, s.links))...)
+	s.engine.DELETE(fmt.Sprintf("%s/%s/:code", ctx, commons.UrlPath),
+		s.guard(shortner.DeleteShortURL(s.links))...)
+	s.engine.GET(fmt.Sprintf("%s/%s/:code/stats", ctx, commons.UrlPath),
+		s.guard(shortner.LinkStats(s.links, s.links))...)
+	s.engine.GET(fmt.Sprintf("%s/%s/:code/clicks/export", ctx, commons.UrlPath),
+		s.guard(shortner.ExportClicks(s.links, s.links))...)
+	redirect := shortner.RedirectURL(cfg, s.links, s.recorder, s.geo)
+	s.engine.GET(fmt.Sprintf("%s/%s/:s", ctx, commons.ShortenerPath), redirectLimit, redirect)
+	// the links of the other workspaces are served under the name of their workspace
+	s.engine.GET(fmt.Sprintf("%s/%s/:s/:code", ctx, commons.ShortenerPath), redirectLimit, redirect)
+	s.engine.GET(fmt.Sprintf("%s/%s/:id/links", ctx, commons.UsersPath),
+		s.guard(shortner.ListUserLinks(cfg, s.links))...)
+	s.engine.GET(fmt.Sprintf("%s/%s/:id/clicks/export", ctx, commons.UsersPath),
+		s.guard(shortner.ExportClicks(s.links, s.links))...)
+
+	// the keys can only be managed when the API requires them
+	if s.authenticate != nil {
+		admin := middleware.RequireScope(auth.ScopeAdmin)
+		s.engine.POST(fmt.Sprintf("%s/%s/keys", ctx, commons.AdminPath), s.guard(admin, keys.IssueKey(s.links))...)
+		s.engine.GET(fmt.Sprintf("%s/%s/keys/:id", ctx, commons.AdminPath), s.guard(admin, keys.GetKey(s.links))...)
+		s.engine.DELETE(fmt.Sprintf("%s/%s/keys/:id", ctx, commons.AdminPath), s.guard(admin, keys.RevokeKey(s.links))...)
+	}
+}
+
+// guard puts the authentication, unless the management API is open, and the workspace of the request in front of
+// handlers. The shortner handlers check the role of the principal themselves
+func (s *Server) guard(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	if s.authenticate == nil {
+		return append([]gin.HandlerFunc{middleware.Workspace()}, handlers...)
+	}
+	return append([]gin.HandlerFunc{s.authenticate, middleware.Workspace()}, handlers...)
 }
 
 func serverContext(ctx context.Context) context.Context {
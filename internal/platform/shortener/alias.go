@@ -0,0 +1,32 @@
+package shortener
+
+import (
+	"errors"
+	"github.com/alexperezortuno/go-url-shortner/internal/commons"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	MinAliasLength = 3
+	MaxAliasLength = 32
+)
+
+var (
+	ErrInvalidAlias  = errors.New("alias must be 3 to 32 letters, digits, '-' or '_'")
+	ErrReservedAlias = errors.New("alias is reserved")
+
+	aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// ValidateAlias checks that a custom alias can be used as a short code
+func ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength || !aliasPattern.MatchString(alias) {
+		return ErrInvalidAlias
+	}
+	if slices.Contains(commons.ReservedAliases, strings.ToLower(alias)) {
+		return ErrReservedAlias
+	}
+	return nil
+}
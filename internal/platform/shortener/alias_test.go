@@ -0,0 +1,26 @@
+package shortener
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAliasAcceptsValidAliases(t *testing.T) {
+	for _, alias := range []string{"abc", "my-link", "My_Link_2025", strings.Repeat("a", MaxAliasLength)} {
+		assert.NoError(t, ValidateAlias(alias), alias)
+	}
+}
+
+func TestValidateAliasRejectsInvalidAliases(t *testing.T) {
+	for _, alias := range []string{"", "ab", "with space", "slash/alias", "dot.alias", "ñandú", strings.Repeat("a", MaxAliasLength+1)} {
+		assert.ErrorIs(t, ValidateAlias(alias), ErrInvalidAlias, alias)
+	}
+}
+
+func TestValidateAliasRejectsReservedWords(t *testing.T) {
+	for _, alias := range []string{"health", "url", "HEALTH", "Url"} {
+		assert.ErrorIs(t, ValidateAlias(alias), ErrReservedAlias, alias)
+	}
+}
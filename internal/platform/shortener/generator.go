@@ -0,0 +1,139 @@
+package shortener
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/alexperezortuno/go-url-shortner/internal/config"
+	"math/big"
+	"strings"
+)
+
+const (
+	StrategyHash    = "hash"
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+
+	// Base62Alphabet is the default alphabet of the random and counter strategies
+	Base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	MinCodeLength = 4
+	MaxCodeLength = 64
+)
+
+var ErrInvalidAlphabet = errors.New("alphabet must have at least two distinct letters, digits, '-' or '_'")
+
+// Request is the input a Generator may use to build a short code
+type Request struct {
+	LongURL string
+	UserId  string
+	// Attempt is zero on the first try and grows after every collision
+	Attempt int
+}
+
+// Generator builds short code candidates
+type Generator interface {
+	Generate(ctx context.Context, request Request) (string, error)
+}
+
+// Counter is an atomic sequence shared by every replica, implemented by the storage backends
+type Counter interface {
+	NextSequence(ctx context.Context) (uint64, error)
+}
+
+// HashGenerator derives the code from the SHA-256 of the long URL and the user, so it is stable across requests
+type HashGenerator struct{}
+
+func (HashGenerator) Generate(_ context.Context, request Request) (string, error) {
+	return GenerateCandidate(request.LongURL, request.UserId, request.Attempt), nil
+}
+
+// RandomGenerator draws unguessable codes from a crypto-secure source, retries are one character longer
+type RandomGenerator struct {
+	Alphabet string
+	Length   int
+}
+
+func (g RandomGenerator) Generate(_ context.Context, request Request) (string, error) {
+	size := big.NewInt(int64(len(g.Alphabet)))
+	code := make([]byte, g.Length+request.Attempt)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		code[i] = g.Alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// CounterGenerator encodes the next value of a monotonic counter, which gives short and sequential codes
+type CounterGenerator struct {
+	Counter  Counter
+	Alphabet string
+}
+
+func (g CounterGenerator) Generate(ctx context.Context, _ Request) (string, error) {
+	value, err := g.Counter.NextSequence(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encode(value, g.Alphabet), nil
+}
+
+func encode(value uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	if value == 0 {
+		return alphabet[:1]
+	}
+
+	var encoded []byte
+	for value > 0 {
+		encoded = append(encoded, alphabet[value%base])
+		value /= base
+	}
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// NewGenerators builds every strategy from the configuration keyed by name, the configured default must be one of them
+func NewGenerators(cfg *config.Config, counter Counter) (map[string]Generator, error) {
+	alphabet := cfg.CodeAlphabet
+	if alphabet == "" {
+		alphabet = Base62Alphabet
+	}
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if cfg.CodeLength < MinCodeLength || cfg.CodeLength > MaxCodeLength {
+		return nil, fmt.Errorf("code length must be between %d and %d: %d", MinCodeLength, MaxCodeLength, cfg.CodeLength)
+	}
+
+	generators := map[string]Generator{
+		StrategyHash:    HashGenerator{},
+		StrategyRandom:  RandomGenerator{Alphabet: alphabet, Length: cfg.CodeLength},
+		StrategyCounter: CounterGenerator{Counter: counter, Alphabet: alphabet},
+	}
+	if _, ok := generators[cfg.CodeGenerator]; !ok {
+		return nil, fmt.Errorf("invalid code generator: %s", cfg.CodeGenerator)
+	}
+	return generators, nil
+}
+
+func validateAlphabet(alphabet string) error {
+	if !aliasPattern.MatchString(alphabet) {
+		return ErrInvalidAlphabet
+	}
+	for i := range alphabet {
+		if strings.IndexByte(alphabet, alphabet[i]) != i {
+			return ErrInvalidAlphabet
+		}
+	}
+	if len(alphabet) < 2 {
+		return ErrInvalidAlphabet
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package shortener
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sequence struct {
+	value uint64
+}
+
+func (s *sequence) NextSequence(context.Context) (uint64, error) {
+	s.value++
+	return s.value, nil
+}
+
+func TestHashGeneratorMatchesGenerateCandidate(t *testing.T) {
+	code, err := HashGenerator{}.Generate(context.Background(), Request{LongURL: "https://example.com", UserId: "1", Attempt: 2})
+	require.NoError(t, err)
+	assert.Equal(t, GenerateCandidate("https://example.com", "1", 2), code)
+}
+
+func TestRandomGeneratorUsesAlphabetAndLength(t *testing.T) {
+	g := RandomGenerator{Alphabet: "abc", Length: 12}
+	ctx := context.Background()
+
+	code, err := g.Generate(ctx, Request{})
+	require.NoError(t, err)
+	assert.Len(t, code, 12)
+	assert.Empty(t, strings.Trim(code, "abc"))
+
+	retry, err := g.Generate(ctx, Request{Attempt: 1})
+	require.NoError(t, err)
+	assert.Len(t, retry, 13)
+}
+
+func TestCounterGeneratorEncodesSequentialCodes(t *testing.T) {
+	g := CounterGenerator{Counter: &sequence{value: 60}, Alphabet: Base62Alphabet}
+	ctx := context.Background()
+
+	var codes []string
+	for i := 0; i < 3; i++ {
+		code, err := g.Generate(ctx, Request{})
+		require.NoError(t, err)
+		codes = append(codes, code)
+	}
+	assert.Equal(t, []string{"Z", "10", "11"}, codes)
+}
+
+func TestNewGeneratorsValidatesConfiguration(t *testing.T) {
+	cfg := &config.Config{CodeGenerator: StrategyRandom, CodeLength: 8}
+	generators, err := NewGenerators(cfg, &sequence{})
+	require.NoError(t, err)
+	assert.Contains(t, generators, StrategyHash)
+	assert.Contains(t, generators, StrategyRandom)
+	assert.Contains(t, generators, StrategyCounter)
+
+	for _, invalid := range []*config.Config{
+		{CodeGenerator: "unknown", CodeAlphabet: Base62Alphabet, CodeLength: 8},
+		{CodeGenerator: StrategyHash, CodeAlphabet: "aab", CodeLength: 8},
+		{CodeGenerator: StrategyHash, CodeAlphabet: "ab/", CodeLength: 8},
+		{CodeGenerator: StrategyHash, CodeAlphabet: Base62Alphabet, CodeLength: 2},
+	} {
+		_, err := NewGenerators(invalid, &sequence{})
+		assert.Error(t, err)
+	}
+}
@@ -1,32 +1,53 @@
 package shortener
 
 import (
-    "crypto/sha256"
-    "fmt"
-    "github.com/itchyny/base58-go"
-    "math/big"
-    "os"
+	"crypto/sha256"
+	"fmt"
+	"github.com/itchyny/base58-go"
+	"math/big"
+	"os"
+)
+
+const (
+	// DefaultLength is the length of the code tried first
+	DefaultLength = 8
+	// MaxAttempts bounds how many candidates are tried before giving up on a colliding code
+	MaxAttempts = 8
 )
 
 func sha256Of(input string) []byte {
-    algorithm := sha256.New()
-    algorithm.Write([]byte(input))
-    return algorithm.Sum(nil)
+	algorithm := sha256.New()
+	algorithm.Write([]byte(input))
+	return algorithm.Sum(nil)
 }
 
 func base58Encoded(bytes []byte) string {
-    encoding := base58.BitcoinEncoding
-    encoded, err := encoding.Encode(bytes)
-    if err != nil {
-        fmt.Println(err.Error())
-        os.Exit(1)
-    }
-    return string(encoded)
+	encoding := base58.BitcoinEncoding
+	encoded, err := encoding.Encode(bytes)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return string(encoded)
+}
+
+func encodedHash(input string) string {
+	urlHashBytes := sha256Of(input)
+	generatedNumber := new(big.Int).SetBytes(urlHashBytes).Uint64()
+	return base58Encoded([]byte(fmt.Sprintf("%d", generatedNumber)))
 }
 
 func GenerateShortURL(initialLink string, userId string) string {
-    urlHashBytes := sha256Of(initialLink + userId)
-    generatedNumber := new(big.Int).SetBytes(urlHashBytes).Uint64()
-    finalString := base58Encoded([]byte(fmt.Sprintf("%d", generatedNumber)))
-    return finalString[:8]
+	return GenerateCandidate(initialLink, userId, 0)
+}
+
+// GenerateCandidate returns the code to try on the given attempt. Retries after a collision hash the input salted
+// with the attempt number and keep one more character each time
+func GenerateCandidate(initialLink string, userId string, attempt int) string {
+	input := initialLink + userId
+	if attempt > 0 {
+		input = fmt.Sprintf("%s#%d", input, attempt)
+	}
+	finalString := encodedHash(input)
+	return finalString[:min(DefaultLength+attempt, len(finalString))]
 }
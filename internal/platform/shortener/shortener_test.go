@@ -0,0 +1,27 @@
+package shortener
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateShortURLIsDeterministic(t *testing.T) {
+	first := GenerateShortURL("https://example.com", "1")
+
+	assert.Len(t, first, DefaultLength)
+	assert.Equal(t, first, GenerateShortURL("https://example.com", "1"))
+	assert.NotEqual(t, first, GenerateShortURL("https://example.com", "2"))
+}
+
+func TestGenerateCandidateChangesOnEveryAttempt(t *testing.T) {
+	seen := map[string]bool{}
+
+	for attempt := 0; attempt < MaxAttempts; attempt++ {
+		candidate := GenerateCandidate("https://example.com", "1", attempt)
+		assert.GreaterOrEqual(t, len(candidate), DefaultLength)
+		assert.False(t, seen[candidate], "candidate %s repeated", candidate)
+		seen[candidate] = true
+	}
+	assert.Greater(t, len(GenerateCandidate("https://example.com", "1", 2)), DefaultLength)
+}
@@ -0,0 +1,10 @@
+CREATE TABLE links (
+    code       TEXT PRIMARY KEY,
+    long_url   TEXT      NOT NULL,
+    user_id    TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NULL,
+    metadata   TEXT      NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX idx_links_user_id ON links (user_id, created_at);
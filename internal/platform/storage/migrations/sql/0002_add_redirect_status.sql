@@ -0,0 +1 @@
+ALTER TABLE links ADD COLUMN redirect_status INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+CREATE TABLE sequences (
+    name  TEXT PRIMARY KEY,
+    value INTEGER NOT NULL
+);
+
+INSERT INTO sequences (name, value) VALUES ('codes', 0);
@@ -0,0 +1,3 @@
+ALTER TABLE links ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+
+CREATE INDEX idx_links_user_clicks ON links (user_id, clicks, code);
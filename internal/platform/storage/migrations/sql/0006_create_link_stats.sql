@@ -0,0 +1,7 @@
+CREATE TABLE link_stats (
+    code  TEXT    NOT NULL,
+    day   TEXT    NOT NULL,
+    field TEXT    NOT NULL,
+    value INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (code, day, field)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE link_visitors (
+    code    TEXT NOT NULL,
+    day     TEXT NOT NULL,
+    visitor TEXT NOT NULL,
+    PRIMARY KEY (code, day, visitor)
+);
@@ -0,0 +1,8 @@
+DROP TABLE link_visitors;
+
+CREATE TABLE link_sketches (
+    code   TEXT NOT NULL,
+    day    TEXT NOT NULL,
+    sketch BLOB NOT NULL,
+    PRIMARY KEY (code, day)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE click_log (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT    NOT NULL,
+    time INTEGER NOT NULL,
+    data TEXT    NOT NULL
+);
+
+CREATE INDEX idx_click_log_code_time ON click_log (code, time, id);
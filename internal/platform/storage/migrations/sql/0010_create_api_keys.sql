@@ -0,0 +1,12 @@
+CREATE TABLE api_keys (
+    id           TEXT PRIMARY KEY,
+    hash         TEXT      NOT NULL,
+    user_id      TEXT      NOT NULL,
+    name         TEXT      NOT NULL DEFAULT '',
+    scopes       TEXT      NOT NULL DEFAULT '[]',
+    created_at   TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NULL,
+    revoked_at   TIMESTAMP NULL
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
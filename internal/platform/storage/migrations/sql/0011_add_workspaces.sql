@@ -0,0 +1,56 @@
+CREATE TABLE links_scoped (
+    workspace       TEXT      NOT NULL DEFAULT '',
+    code            TEXT      NOT NULL,
+    long_url        TEXT      NOT NULL,
+    user_id         TEXT      NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    expires_at      TIMESTAMP NULL,
+    metadata        TEXT      NOT NULL DEFAULT '{}',
+    redirect_status INTEGER   NOT NULL DEFAULT 0,
+    max_clicks      INTEGER   NOT NULL DEFAULT 0,
+    clicks          INTEGER   NOT NULL DEFAULT 0,
+    tags            TEXT      NOT NULL DEFAULT '[]',
+    PRIMARY KEY (workspace, code)
+);
+
+INSERT INTO links_scoped (code, long_url, user_id, created_at, expires_at, metadata, redirect_status, max_clicks,
+                          clicks, tags)
+SELECT code, long_url, user_id, created_at, expires_at, metadata, redirect_status, max_clicks, clicks, tags
+FROM links;
+
+DROP TABLE links;
+ALTER TABLE links_scoped RENAME TO links;
+
+CREATE INDEX idx_links_user_id ON links (workspace, user_id, created_at);
+CREATE INDEX idx_links_user_clicks ON links (workspace, user_id, clicks, code);
+
+CREATE TABLE link_stats_scoped (
+    workspace TEXT    NOT NULL DEFAULT '',
+    code      TEXT    NOT NULL,
+    day       TEXT    NOT NULL,
+    field     TEXT    NOT NULL,
+    value     INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (workspace, code, day, field)
+);
+
+INSERT INTO link_stats_scoped (code, day, field, value) SELECT code, day, field, value FROM link_stats;
+DROP TABLE link_stats;
+ALTER TABLE link_stats_scoped RENAME TO link_stats;
+
+CREATE TABLE link_sketches_scoped (
+    workspace TEXT NOT NULL DEFAULT '',
+    code      TEXT NOT NULL,
+    day       TEXT NOT NULL,
+    sketch    BLOB NOT NULL,
+    PRIMARY KEY (workspace, code, day)
+);
+
+INSERT INTO link_sketches_scoped (code, day, sketch) SELECT code, day, sketch FROM link_sketches;
+DROP TABLE link_sketches;
+ALTER TABLE link_sketches_scoped RENAME TO link_sketches;
+
+ALTER TABLE click_log ADD COLUMN workspace TEXT NOT NULL DEFAULT '';
+DROP INDEX idx_click_log_code_time;
+CREATE INDEX idx_click_log_code_time ON click_log (workspace, code, time, id);
+
+ALTER TABLE api_keys ADD COLUMN workspace TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_links_expires_at ON links (expires_at);
+CREATE INDEX IF NOT EXISTS idx_link_stats_day ON link_stats (day);
+CREATE INDEX IF NOT EXISTS idx_link_sketches_day ON link_sketches (day);
+CREATE INDEX IF NOT EXISTS idx_click_log_time ON click_log (time);
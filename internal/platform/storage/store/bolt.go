@@ -0,0 +1,582 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/hll"
+	bolt "go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+)
+
+var (
+	linksBucket    = []byte("links")
+	ownersBucket   = []byte("owners")
+	sequenceBucket = []byte("sequence")
+	// statsBucket holds a bucket per code with the JSON encoded counters of each day
+	statsBucket = []byte("stats")
+	// sketchesBucket holds a bucket per code with the visitors sketch of each day
+	sketchesBucket = []byte("sketches")
+	// clickLogBucket holds a bucket per code with the raw clicks keyed by time and sequence
+	clickLogBucket = []byte("clicklog")
+	// keysBucket holds the JSON encoded API keys by id
+	keysBucket = []byte("keys")
+	// legacyVisitorsBucket held the exact visitor sets, the sketches replaced it
+	legacyVisitorsBucket = []byte("visitors")
+)
+
+// BoltStore is a LinkRepository persisted in a single bbolt data file, expired links are only dropped by Purge. The
+// workspaces share the buckets, their codes and user ids are scoped with scopedId
+type BoltStore struct {
+	db *bolt.DB
+}
+
+var (
+	_ Repository = (*BoltStore)(nil)
+	_ Purger     = (*BoltStore)(nil)
+)
+
+// NewBoltStore opens or creates the data file at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt file %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{linksBucket, ownersBucket, sequenceBucket, statsBucket, sketchesBucket,
+			clickLogBucket, keysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if err := tx.DeleteBucket(legacyVisitorsBucket); err != nil && !errors.Is(err, berrors.ErrBucketNotFound) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close releases the file lock held on the data file
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+func (b *BoltStore) Save(ctx context.Context, link *Link) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	workspace := WorkspaceFrom(ctx)
+	code := bucketKey(workspace, link.Code)
+	return storageError(b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(linksBucket).Get(code) != nil {
+			return ErrConflict
+		}
+		if err := tx.Bucket(linksBucket).Put(code, data); err != nil {
+			return err
+		}
+		return indexOwner(tx, workspace, link)
+	}))
+}
+
+func (b *BoltStore) Get(ctx context.Context, code string) (*Link, error) {
+	var link Link
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(linksBucket).Get(bucketKey(WorkspaceFrom(ctx), code))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &link)
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &link, nil
+}
+
+func (b *BoltStore) Update(ctx context.Context, link *Link) error {
+	workspace := WorkspaceFrom(ctx)
+	code := bucketKey(workspace, link.Code)
+	return storageError(b.db.Update(func(tx *bolt.Tx) error {
+		previous := tx.Bucket(linksBucket).Get(code)
+		if previous == nil {
+			return ErrNotFound
+		}
+		var stored Link
+		if err := json.Unmarshal(previous, &stored); err != nil {
+			return err
+		}
+		if err := unindexOwner(tx, workspace, previous, link.Code); err != nil {
+			return err
+		}
+
+		updated := *link
+		updated.Clicks = stored.Clicks
+		data, err := json.Marshal(&updated)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(linksBucket).Put(code, data); err != nil {
+			return err
+		}
+		return indexOwner(tx, workspace, link)
+	}))
+}
+
+func (b *BoltStore) Delete(ctx context.Context, code string) error {
+	workspace := WorkspaceFrom(ctx)
+	return storageError(b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(linksBucket).Get(bucketKey(workspace, code)) == nil {
+			return ErrNotFound
+		}
+		return evict(tx, workspace, code)
+	}))
+}
+
+func (b *BoltStore) List(ctx context.Context, userId string) ([]*Link, error) {
+	workspace := WorkspaceFrom(ctx)
+	links := []*Link{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		owner := tx.Bucket(ownersBucket).Bucket(bucketKey(workspace, userId))
+		if owner == nil {
+			return nil
+		}
+		all := tx.Bucket(linksBucket)
+		return owner.ForEach(func(code, _ []byte) error {
+			data := all.Get(bucketKey(workspace, string(code)))
+			if data == nil {
+				return nil
+			}
+			var link Link
+			if err := json.Unmarshal(data, &link); err != nil {
+				return err
+			}
+			links = append(links, &link)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return links, nil
+}
+
+func (b *BoltStore) Hit(ctx context.Context, link *Link) (int64, error) {
+	code := bucketKey(WorkspaceFrom(ctx), link.Code)
+	var clicks int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		data := tx.Bucket(linksBucket).Get(code)
+		if data == nil {
+			return ErrNotFound
+		}
+		var stored Link
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+		clicks = stored.Clicks
+		if stored.MaxClicks > 0 && stored.Clicks >= stored.MaxClicks {
+			return ErrExhausted
+		}
+
+		stored.Clicks++
+		clicks = stored.Clicks
+		updated, err := json.Marshal(&stored)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(linksBucket).Put(code, updated)
+	})
+	return clicks, storageError(err)
+}
+
+func (b *BoltStore) Find(ctx context.Context, query LinkQuery) (*LinkPage, error) {
+	links, err := b.List(ctx, query.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return paginate(links, query)
+}
+
+func (b *BoltStore) IncrementCounters(ctx context.Context, code string, day time.Time, deltas map[string]int64) error {
+	key := []byte(day.UTC().Format(DayLayout))
+	return storageError(b.db.Update(func(tx *bolt.Tx) error {
+		stats, err := tx.Bucket(statsBucket).CreateBucketIfNotExists(bucketKey(WorkspaceFrom(ctx), code))
+		if err != nil {
+			return err
+		}
+		counters := map[string]int64{}
+		if data := stats.Get(key); data != nil {
+			if err := json.Unmarshal(data, &counters); err != nil {
+				return err
+			}
+		}
+		for field, delta := range deltas {
+			counters[field] += delta
+		}
+		data, err := json.Marshal(counters)
+		if err != nil {
+			return err
+		}
+		return stats.Put(key, data)
+	}))
+}
+
+func (b *BoltStore) Counters(ctx context.Context, code string, from, to time.Time) ([]DailyCounters, error) {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	counters := []DailyCounters{}
+	err = b.db.View(func(tx *bolt.Tx) error {
+		stats := tx.Bucket(statsBucket).Bucket(bucketKey(WorkspaceFrom(ctx), code))
+		if stats == nil {
+			return nil
+		}
+		for _, day := range days {
+			data := stats.Get([]byte(day))
+			if data == nil {
+				continue
+			}
+			values := map[string]int64{}
+			if err := json.Unmarshal(data, &values); err != nil {
+				return err
+			}
+			counters = append(counters, DailyCounters{Day: day, Counters: values})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return counters, nil
+}
+
+func (b *BoltStore) AddVisitors(ctx context.Context, code string, day time.Time, visitors []string) error {
+	key := []byte(day.UTC().Format(DayLayout))
+	return storageError(b.db.Update(func(tx *bolt.Tx) error {
+		sketches, err := tx.Bucket(sketchesBucket).CreateBucketIfNotExists(bucketKey(WorkspaceFrom(ctx), code))
+		if err != nil {
+			return err
+		}
+		sketch := hll.New()
+		if data := sketches.Get(key); data != nil {
+			if err := sketch.UnmarshalBinary(data); err != nil {
+				return err
+			}
+		}
+		for _, visitor := range visitors {
+			sketch.Add(visitor)
+		}
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return sketches.Put(key, data)
+	}))
+}
+
+func (b *BoltStore) CountVisitors(ctx context.Context, code string, from, to time.Time) (int64, error) {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	union := hll.New()
+	err = b.db.View(func(tx *bolt.Tx) error {
+		sketches := tx.Bucket(sketchesBucket).Bucket(bucketKey(WorkspaceFrom(ctx), code))
+		if sketches == nil {
+			return nil
+		}
+		for _, day := range days {
+			data := sketches.Get([]byte(day))
+			if data == nil {
+				continue
+			}
+			sketch := hll.New()
+			if err := sketch.UnmarshalBinary(data); err != nil {
+				return err
+			}
+			union.Merge(sketch)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return union.Count(), nil
+}
+
+// Purge walks every link and every analytics bucket in a single transaction, the keys to delete are collected first
+// because deleting under a cursor skips the next key
+func (b *BoltStore) Purge(_ context.Context, now time.Time) error {
+	expired := evictionCutoff(now)
+	cutoff := statsCutoff(now)
+	return storageError(b.db.Update(func(tx *bolt.Tx) error {
+		var evicted [][]byte
+		err := tx.Bucket(linksBucket).ForEach(func(key, data []byte) error {
+			var link Link
+			if err := json.Unmarshal(data, &link); err != nil {
+				return err
+			}
+			if link.ExpiresAt != nil && !link.ExpiresAt.After(expired) {
+				evicted = append(evicted, bytes.Clone(key))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range evicted {
+			workspace, code := splitScopedId(string(key))
+			if err := evict(tx, workspace, code); err != nil {
+				return err
+			}
+		}
+
+		first := []byte(cutoff.Format(DayLayout))
+		for _, name := range [][]byte{statsBucket, sketchesBucket} {
+			if err := purgeBefore(tx.Bucket(name), first); err != nil {
+				return err
+			}
+		}
+		return purgeBefore(tx.Bucket(clickLogBucket), clickLogEntryKey(cutoff, 0))
+	}))
+}
+
+// evict deletes the stored link of workspace with its owner index entry and its analytics
+func evict(tx *bolt.Tx, workspace, code string) error {
+	key := bucketKey(workspace, code)
+	if err := unindexOwner(tx, workspace, tx.Bucket(linksBucket).Get(key), code); err != nil {
+		return err
+	}
+	for _, name := range [][]byte{statsBucket, sketchesBucket, clickLogBucket} {
+		if err := tx.Bucket(name).DeleteBucket(key); err != nil && !errors.Is(err, berrors.ErrBucketNotFound) {
+			return err
+		}
+	}
+	return tx.Bucket(linksBucket).Delete(key)
+}
+
+// purgeBefore deletes the keys sorting before first in every code bucket of parent, and the buckets left empty
+func purgeBefore(parent *bolt.Bucket, first []byte) error {
+	var codes [][]byte
+	err := parent.ForEachBucket(func(code []byte) error {
+		codes = append(codes, bytes.Clone(code))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		entries := parent.Bucket(code)
+		var old [][]byte
+		cursor := entries.Cursor()
+		for key, _ := cursor.First(); key != nil && bytes.Compare(key, first) < 0; key, _ = cursor.Next() {
+			old = append(old, bytes.Clone(key))
+		}
+		for _, key := range old {
+			if err := entries.Delete(key); err != nil {
+				return err
+			}
+		}
+		if key, _ := entries.Cursor().First(); key == nil {
+			if err := parent.DeleteBucket(code); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// NextSequence counts with the sequence bucket in the default workspace and with a bucket nested in it in the others
+func (b *BoltStore) NextSequence(ctx context.Context) (uint64, error) {
+	var value uint64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		sequence := tx.Bucket(sequenceBucket)
+		if workspace := WorkspaceFrom(ctx); workspace != DefaultWorkspace {
+			var err error
+			if sequence, err = sequence.CreateBucketIfNotExists([]byte(workspace)); err != nil {
+				return err
+			}
+		}
+		var err error
+		value, err = sequence.NextSequence()
+		return err
+	})
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return value, nil
+}
+
+func indexOwner(tx *bolt.Tx, workspace string, link *Link) error {
+	owner, err := tx.Bucket(ownersBucket).CreateBucketIfNotExists(bucketKey(workspace, link.UserId))
+	if err != nil {
+		return err
+	}
+	return owner.Put([]byte(link.Code), nil)
+}
+
+func unindexOwner(tx *bolt.Tx, workspace string, data []byte, code string) error {
+	var link Link
+	if err := json.Unmarshal(data, &link); err != nil {
+		return err
+	}
+	owner := tx.Bucket(ownersBucket).Bucket(bucketKey(workspace, link.UserId))
+	if owner == nil {
+		return nil
+	}
+	return owner.Delete([]byte(code))
+}
+
+func (b *BoltStore) AppendClicks(ctx context.Context, clicks []ClickRecord) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	workspace := WorkspaceFrom(ctx)
+	return storageError(b.db.Update(func(tx *bolt.Tx) error {
+		for _, click := range clicks {
+			entries, err := tx.Bucket(clickLogBucket).CreateBucketIfNotExists(bucketKey(workspace, click.Code))
+			if err != nil {
+				return err
+			}
+			sequence, err := entries.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := entries.Put(clickLogEntryKey(click.Time, sequence), click.Data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func (b *BoltStore) ScanClicks(ctx context.Context, code string, from, to time.Time, fn func(ClickRecord) error) error {
+	start, end, err := clickLogRange(from, to)
+	if err != nil {
+		return err
+	}
+
+	// every page is read in its own transaction so a slow consumer never holds the file open
+	seek, last := clickLogEntryKey(start, 0), clickLogEntryKey(end, 0)
+	for seek != nil {
+		var page []ClickRecord
+		err := b.db.View(func(tx *bolt.Tx) error {
+			entries := tx.Bucket(clickLogBucket).Bucket(bucketKey(WorkspaceFrom(ctx), code))
+			if entries == nil {
+				seek = nil
+				return nil
+			}
+			cursor := entries.Cursor()
+			key, data := cursor.Seek(seek)
+			for ; key != nil && bytes.Compare(key, last) < 0 && len(page) < clickLogPageSize; key, data = cursor.Next() {
+				nanos := int64(binary.BigEndian.Uint64(key))
+				page = append(page, ClickRecord{Code: code, Time: time.Unix(0, nanos).UTC(), Data: bytes.Clone(data)})
+			}
+			seek = nil
+			if key != nil && bytes.Compare(key, last) < 0 {
+				seek = bytes.Clone(key)
+			}
+			return nil
+		})
+		if err != nil {
+			return storageError(err)
+		}
+		for _, click := range page {
+			if err := fn(click); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bucketKey is the bucket key of id in workspace
+func bucketKey(workspace, id string) []byte {
+	return []byte(scopedId(workspace, id))
+}
+
+// clickLogEntryKey sorts the clicks by time, the sequence tells apart the clicks of the same nanosecond
+func clickLogEntryKey(t time.Time, sequence uint64) []byte {
+	key := binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))
+	return binary.BigEndian.AppendUint64(key, sequence)
+}
+
+func (b *BoltStore) SaveKey(_ context.Context, key *APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	return storageError(b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(keysBucket).Get([]byte(key.Id)) != nil {
+			return ErrConflict
+		}
+		return tx.Bucket(keysBucket).Put([]byte(key.Id), data)
+	}))
+}
+
+func (b *BoltStore) GetKey(_ context.Context, id string) (*APIKey, error) {
+	var key APIKey
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(keysBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &key)
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &key, nil
+}
+
+func (b *BoltStore) RevokeKey(_ context.Context, id string, at time.Time) error {
+	return b.updateKey(id, func(key *APIKey) {
+		if key.RevokedAt == nil {
+			key.RevokedAt = &at
+		}
+	})
+}
+
+func (b *BoltStore) TouchKey(_ context.Context, id string, at time.Time) error {
+	return b.updateKey(id, func(key *APIKey) {
+		key.LastUsedAt = &at
+	})
+}
+
+// updateKey applies change to the stored key in a single transaction
+func (b *BoltStore) updateKey(id string, change func(key *APIKey)) error {
+	return storageError(b.db.Update(func(tx *bolt.Tx) error {
+		data := tx.Bucket(keysBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		var key APIKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			return err
+		}
+		change(&key)
+		data, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(keysBucket).Put([]byte(id), data)
+	}))
+}
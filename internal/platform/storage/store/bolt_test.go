@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBoltStore(t *testing.T, path string) *BoltStore {
+	b, err := NewBoltStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+func TestBoltStoreKeepsLinksAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.db")
+	ctx := context.Background()
+
+	b, err := NewBoltStore(path)
+	require.NoError(t, err)
+	require.NoError(t, b.Save(ctx, &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+	require.NoError(t, b.Close())
+
+	b = newTestBoltStore(t, path)
+	got, err := b.Get(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", got.LongURL)
+
+	_, err = b.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestBoltStoreUpdateDeleteAndList(t *testing.T) {
+	b := newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db"))
+	ctx := context.Background()
+
+	assert.ErrorIs(t, b.Update(ctx, &Link{Code: "missing"}), ErrNotFound)
+
+	require.NoError(t, b.Save(ctx, &Link{Code: "first", LongURL: "https://example.com/1", UserId: "1"}))
+	require.NoError(t, b.Save(ctx, &Link{Code: "second", LongURL: "https://example.com/2", UserId: "1"}))
+	require.NoError(t, b.Update(ctx, &Link{Code: "second", LongURL: "https://example.org/2", UserId: "2"}))
+
+	links, err := b.List(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "first", links[0].Code)
+
+	links, err = b.List(ctx, "2")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "https://example.org/2", links[0].LongURL)
+
+	require.NoError(t, b.Delete(ctx, "first"))
+	assert.ErrorIs(t, b.Delete(ctx, "first"), ErrNotFound)
+
+	links, err = b.List(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, links)
+}
+
+func TestBoltStoreSaveReturnsConflictWhenCodeIsTaken(t *testing.T) {
+	s := newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db"))
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, &Link{Code: "taken", LongURL: "https://example.com/1", UserId: "1"}))
+	assert.ErrorIs(t, s.Save(ctx, &Link{Code: "taken", LongURL: "https://example.com/2", UserId: "2"}), ErrConflict)
+
+	got, err := s.Get(ctx, "taken")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", got.LongURL)
+}
+
+func TestBoltStoreNextSequenceIncrements(t *testing.T) {
+	s := newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db"))
+	ctx := context.Background()
+
+	first, err := s.NextSequence(ctx)
+	require.NoError(t, err)
+	second, err := s.NextSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, first+1, second)
+}
+
+func TestBoltStoreHitEnforcesMaxClicks(t *testing.T) {
+	s := newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db"))
+	ctx := context.Background()
+	link := &Link{Code: "twice", LongURL: "https://example.com", UserId: "1", MaxClicks: 2}
+	require.NoError(t, s.Save(ctx, link))
+
+	for want := int64(1); want <= 2; want++ {
+		clicks, err := s.Hit(ctx, link)
+		require.NoError(t, err)
+		assert.Equal(t, want, clicks)
+	}
+	_, err := s.Hit(ctx, link)
+	assert.ErrorIs(t, err, ErrExhausted)
+
+	_, err = s.Hit(ctx, &Link{Code: "missing"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestBoltStoreFind(t *testing.T) {
+	assertFindPagesAndFilters(t, newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db")))
+}
+
+func TestBoltStoreClickAnalytics(t *testing.T) {
+	assertClickAnalytics(t, newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db")))
+	assertVisitorsUnionAcrossDays(t, newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db")))
+}
+
+func TestBoltStoreAPIKeys(t *testing.T) {
+	assertAPIKeys(t, newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db")))
+}
+
+func TestBoltStoreWorkspaces(t *testing.T) {
+	assertWorkspaces(t, newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db")))
+}
+
+func TestBoltStorePurgesDataPastItsRetention(t *testing.T) {
+	assertRetention(t, newTestBoltStore(t, filepath.Join(t.TempDir(), "links.db")), time.Now().UTC())
+}
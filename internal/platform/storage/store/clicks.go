@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// DayLayout formats the UTC day the click counters are aggregated by
+	DayLayout = "2006-01-02"
+	// MaxCounterDays bounds the date range read by Counters
+	MaxCounterDays = 366
+	// StatsRetention is how long the daily counters, visitor sketches and raw clicks of a link are kept, Redis counts
+	// it from the last click of the link and the other backends from the day of each record
+	StatsRetention = 400 * 24 * time.Hour
+	// clickLogPageSize is how many raw clicks ScanClicks reads from the backend at once
+	clickLogPageSize = 500
+)
+
+// DailyCounters are the click counters of a link for one UTC day, keyed by field such as "total"
+type DailyCounters struct {
+	Day      string           `json:"day"`
+	Counters map[string]int64 `json:"counters"`
+}
+
+// ClickRepository stores the aggregated click analytics of the links, every backend implements it
+type ClickRepository interface {
+	// IncrementCounters atomically adds the deltas to the counters of the link for the UTC day of day
+	IncrementCounters(ctx context.Context, code string, day time.Time, deltas map[string]int64) error
+	// Counters returns the counters of the link for the days from from to to, oldest first. Days without clicks are
+	// omitted and ErrInvalidQuery is returned when the range is reversed or longer than MaxCounterDays
+	Counters(ctx context.Context, code string, from, to time.Time) ([]DailyCounters, error)
+}
+
+// VisitorRepository counts the distinct visitors of the links
+type VisitorRepository interface {
+	// AddVisitors records the visitor fingerprints seen for the link on the UTC day of day
+	AddVisitors(ctx context.Context, code string, day time.Time, visitors []string) error
+	// CountVisitors returns how many distinct visitors the link had from from to to, the range is validated like
+	// in Counters
+	CountVisitors(ctx context.Context, code string, from, to time.Time) (int64, error)
+}
+
+// ClickRecord is a raw click of the click log, Data is the JSON event written by the analytics package
+type ClickRecord struct {
+	Code string          `json:"code"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// ClickLogRepository keeps every click so they can be exported
+type ClickLogRepository interface {
+	// AppendClicks adds the clicks to the logs of their links
+	AppendClicks(ctx context.Context, clicks []ClickRecord) error
+	// ScanClicks calls fn with the clicks of the link from the UTC day of from to the day of to, oldest first. The log
+	// is read in pages so it is never loaded whole, the range is validated like in Counters and scanning stops at the
+	// first error returned by fn
+	ScanClicks(ctx context.Context, code string, from, to time.Time, fn func(ClickRecord) error) error
+}
+
+// AnalyticsRepository stores everything recorded about the clicks
+type AnalyticsRepository interface {
+	ClickRepository
+	VisitorRepository
+	ClickLogRepository
+}
+
+// Repository is implemented by every storage backend
+type Repository interface {
+	LinkRepository
+	AnalyticsRepository
+	KeyRepository
+}
+
+// counterDays returns the UTC days of the range
+func counterDays(from, to time.Time) ([]string, error) {
+	first := from.UTC().Truncate(24 * time.Hour)
+	last := to.UTC().Truncate(24 * time.Hour)
+	if last.Before(first) || last.Sub(first) >= MaxCounterDays*24*time.Hour {
+		return nil, ErrInvalidQuery
+	}
+
+	var days []string
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(DayLayout))
+	}
+	return days, nil
+}
+
+// ValidateRange returns ErrInvalidQuery when the range of days is reversed or longer than MaxCounterDays
+func ValidateRange(from, to time.Time) error {
+	_, err := counterDays(from, to)
+	return err
+}
+
+// clickLogRange returns the start of the first day of the range and the end of its last day
+func clickLogRange(from, to time.Time) (time.Time, time.Time, error) {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start, _ := time.Parse(DayLayout, days[0])
+	end, _ := time.Parse(DayLayout, days[len(days)-1])
+	return start, end.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertClickAnalytics runs the same ClickRepository, VisitorRepository and ClickLogRepository scenarios against any
+// backend
+func assertClickAnalytics(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	day := time.Date(2025, 3, 10, 23, 30, 0, 0, time.UTC)
+	require.NoError(t, repo.Save(ctx, &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1", CreatedAt: day}))
+
+	require.NoError(t, repo.IncrementCounters(ctx, "abc123", day, map[string]int64{"total": 1, "hour:23": 1}))
+	require.NoError(t, repo.IncrementCounters(ctx, "abc123", day.Add(time.Minute), map[string]int64{"total": 2}))
+	require.NoError(t, repo.IncrementCounters(ctx, "abc123", day.AddDate(0, 0, 2), map[string]int64{"total": 1}))
+
+	counters, err := repo.Counters(ctx, "abc123", day.AddDate(0, 0, -1), day.AddDate(0, 0, 5))
+	require.NoError(t, err)
+	assert.Equal(t, []DailyCounters{
+		{Day: "2025-03-10", Counters: map[string]int64{"total": 3, "hour:23": 1}},
+		{Day: "2025-03-12", Counters: map[string]int64{"total": 1}},
+	}, counters)
+
+	counters, err = repo.Counters(ctx, "abc123", day.AddDate(0, 0, 1), day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Empty(t, counters)
+
+	_, err = repo.Counters(ctx, "abc123", day, day.AddDate(0, 0, -1))
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = repo.Counters(ctx, "abc123", day, day.AddDate(0, 0, MaxCounterDays))
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+
+	require.NoError(t, repo.AddVisitors(ctx, "abc123", day, []string{"a", "b"}))
+	require.NoError(t, repo.AddVisitors(ctx, "abc123", day, []string{"b"}))
+	visitors, err := repo.CountVisitors(ctx, "abc123", day, day)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), visitors)
+
+	// the first day holds more clicks than a page
+	var clicks []ClickRecord
+	for i := 0; i < clickLogPageSize+3; i++ {
+		clicks = append(clicks, ClickRecord{Code: "abc123", Time: day.Add(time.Duration(i) * time.Millisecond),
+			Data: json.RawMessage(fmt.Sprintf(`{"n":%d}`, i))})
+	}
+	clicks = append(clicks,
+		ClickRecord{Code: "abc123", Time: day.AddDate(0, 0, 2), Data: json.RawMessage(`{"n":"later"}`)},
+		ClickRecord{Code: "other", Time: day, Data: json.RawMessage(`{"n":"other"}`)})
+	require.NoError(t, repo.AppendClicks(ctx, clicks))
+
+	var scanned []ClickRecord
+	require.NoError(t, repo.ScanClicks(ctx, "abc123", day, day.AddDate(0, 0, 2), func(click ClickRecord) error {
+		scanned = append(scanned, click)
+		return nil
+	}))
+	require.Len(t, scanned, clickLogPageSize+4)
+	for i, click := range scanned[:clickLogPageSize+3] {
+		assert.Equal(t, "abc123", click.Code)
+		assert.True(t, clicks[i].Time.Equal(click.Time))
+		assert.JSONEq(t, string(clicks[i].Data), string(click.Data))
+	}
+	assert.JSONEq(t, `{"n":"later"}`, string(scanned[len(scanned)-1].Data))
+
+	scanned = nil
+	require.NoError(t, repo.ScanClicks(ctx, "abc123", day.AddDate(0, 0, 1), day.AddDate(0, 0, 1),
+		func(click ClickRecord) error {
+			scanned = append(scanned, click)
+			return nil
+		}))
+	assert.Empty(t, scanned)
+
+	stop := errors.New("stop")
+	assert.ErrorIs(t, repo.ScanClicks(ctx, "abc123", day, day, func(ClickRecord) error { return stop }), stop)
+	assert.ErrorIs(t, repo.ScanClicks(ctx, "abc123", day, day.AddDate(0, 0, -1), func(ClickRecord) error { return nil }),
+		ErrInvalidQuery)
+
+	// a code reused after a delete starts without counters
+	require.NoError(t, repo.Delete(ctx, "abc123"))
+	counters, err = repo.Counters(ctx, "abc123", day, day.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.Empty(t, counters)
+	visitors, err = repo.CountVisitors(ctx, "abc123", day, day.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.Zero(t, visitors)
+	require.NoError(t, repo.ScanClicks(ctx, "abc123", day, day.AddDate(0, 0, 2), func(ClickRecord) error {
+		t.Fatal("the click log of a deleted link must be empty")
+		return nil
+	}))
+}
+
+// assertVisitorsUnionAcrossDays checks that a visitor seen on several days of the range is counted once
+func assertVisitorsUnionAcrossDays(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.AddVisitors(ctx, "abc123", day, []string{"a", "b"}))
+	require.NoError(t, repo.AddVisitors(ctx, "abc123", day.AddDate(0, 0, 1), []string{"b", "c"}))
+
+	visitors, err := repo.CountVisitors(ctx, "abc123", day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), visitors)
+}
+
+func TestMemoryStoreClickAnalytics(t *testing.T) {
+	assertClickAnalytics(t, NewMemoryStore())
+	assertVisitorsUnionAcrossDays(t, NewMemoryStore())
+}
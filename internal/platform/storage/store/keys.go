@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// APIKey is an API key of a user, only the hash of its secret is stored
+type APIKey struct {
+	Id string `json:"id"`
+	// Hash is the hex encoded SHA-256 of the secret part of the key
+	Hash   string `json:"hash"`
+	UserId string `json:"user_id"`
+	// Workspace is the workspace the key acts in, the keys of every workspace share the same id space so a key can be
+	// found before its workspace is known
+	Workspace string    `json:"workspace,omitempty"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	// LastUsedAt is nil until the key authenticates a request, it is updated with a resolution of a minute
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// RevokedAt is nil while the key is valid
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key was revoked
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// KeyRepository stores the API keys, every backend implements it
+type KeyRepository interface {
+	// SaveKey stores a new key or returns ErrConflict when its id is taken
+	SaveKey(ctx context.Context, key *APIKey) error
+	// GetKey returns the key stored under id or ErrNotFound
+	GetKey(ctx context.Context, id string) (*APIKey, error)
+	// RevokeKey marks the key as revoked at at, revoking a key twice keeps the first time. It returns ErrNotFound for
+	// unknown keys
+	RevokeKey(ctx context.Context, id string, at time.Time) error
+	// TouchKey records that the key was used at at, it returns ErrNotFound for unknown keys
+	TouchKey(ctx context.Context, id string, at time.Time) error
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertAPIKeys runs the same KeyRepository scenarios against any backend
+func assertAPIKeys(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	created := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	key := &APIKey{Id: "k1", Hash: "hash", UserId: "1", Workspace: "eng", Name: "ci", Scopes: []string{"links:read"},
+		CreatedAt: created}
+	require.NoError(t, repo.SaveKey(ctx, key))
+	assert.ErrorIs(t, repo.SaveKey(ctx, &APIKey{Id: "k1", Hash: "other", UserId: "2", CreatedAt: created}), ErrConflict)
+
+	got, err := repo.GetKey(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", got.Hash)
+	assert.Equal(t, "1", got.UserId)
+	assert.Equal(t, "eng", got.Workspace)
+	assert.Equal(t, "ci", got.Name)
+	assert.Equal(t, []string{"links:read"}, got.Scopes)
+	assert.True(t, created.Equal(got.CreatedAt))
+	assert.Nil(t, got.LastUsedAt)
+	assert.False(t, got.Revoked())
+
+	used := created.Add(time.Hour)
+	require.NoError(t, repo.TouchKey(ctx, "k1", used))
+	revoked := created.Add(2 * time.Hour)
+	require.NoError(t, repo.RevokeKey(ctx, "k1", revoked))
+	require.NoError(t, repo.RevokeKey(ctx, "k1", revoked.Add(time.Hour)))
+
+	got, err = repo.GetKey(ctx, "k1")
+	require.NoError(t, err)
+	require.NotNil(t, got.LastUsedAt)
+	assert.True(t, used.Equal(*got.LastUsedAt))
+	require.True(t, got.Revoked())
+	assert.True(t, revoked.Equal(*got.RevokedAt))
+
+	_, err = repo.GetKey(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, repo.TouchKey(ctx, "missing", used), ErrNotFound)
+	assert.ErrorIs(t, repo.RevokeKey(ctx, "missing", revoked), ErrNotFound)
+}
+
+func TestMemoryStoreAPIKeys(t *testing.T) {
+	assertAPIKeys(t, NewMemoryStore())
+}
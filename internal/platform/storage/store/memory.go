@@ -0,0 +1,412 @@
+package store
+
+import (
+	"context"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/hll"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryStore is a concurrency-safe LinkRepository kept in process memory, meant for local development and tests. The
+// maps are keyed by codes and user ids scoped to their workspace
+type MemoryStore struct {
+	mu     sync.RWMutex
+	links  map[string]memoryEntry
+	owners map[string]map[string]struct{}
+	// stats holds the click counters by code, day and field
+	stats map[string]map[string]map[string]int64
+	// visitors holds the visitor sketches by code and day
+	visitors map[string]map[string]*hll.Sketch
+	// clickLog holds the raw clicks by code in the order they were appended. The slices are never changed in place,
+	// Purge replaces them, so ScanClicks can walk a slice after releasing the lock
+	clickLog map[string][]ClickRecord
+	keys     map[string]APIKey
+	// sequences holds the code counter of each workspace
+	sequences map[string]*atomic.Uint64
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	link      Link
+	workspace string
+	// evictAt is zero for links that never expire
+	evictAt time.Time
+}
+
+var (
+	_ Repository = (*MemoryStore)(nil)
+	_ Purger     = (*MemoryStore)(nil)
+)
+
+// NewMemoryStore returns an empty in-memory store, expired links are evicted after ExpiredRetention like in Redis
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		links:     map[string]memoryEntry{},
+		owners:    map[string]map[string]struct{}{},
+		stats:     map[string]map[string]map[string]int64{},
+		visitors:  map[string]map[string]*hll.Sketch{},
+		clickLog:  map[string][]ClickRecord{},
+		keys:      map[string]APIKey{},
+		sequences: map[string]*atomic.Uint64{},
+		now:       time.Now,
+	}
+}
+
+func (m *MemoryStore) Save(ctx context.Context, link *Link) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	workspace := WorkspaceFrom(ctx)
+	code := scopedId(workspace, link.Code)
+	if _, ok := m.lookup(code); ok {
+		return ErrConflict
+	}
+	m.links[code] = m.entry(workspace, link)
+	m.index(scopedId(workspace, link.UserId), code)
+	return nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, code string) (*Link, error) {
+	m.mu.RLock()
+	entry, ok := m.links[scopedId(WorkspaceFrom(ctx), code)]
+	m.mu.RUnlock()
+
+	if !ok || m.expired(entry) {
+		return nil, ErrNotFound
+	}
+	link := entry.link
+	return &link, nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, link *Link) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	workspace := WorkspaceFrom(ctx)
+	code := scopedId(workspace, link.Code)
+	entry, ok := m.lookup(code)
+	if !ok {
+		return ErrNotFound
+	}
+	if entry.link.UserId != link.UserId {
+		m.unindex(scopedId(workspace, entry.link.UserId), code)
+		m.index(scopedId(workspace, link.UserId), code)
+	}
+	updated := *link
+	updated.Clicks = entry.link.Clicks
+	m.links[code] = m.entry(workspace, &updated)
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(scopedId(WorkspaceFrom(ctx), code))
+	if !ok {
+		return ErrNotFound
+	}
+	m.remove(entry)
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, userId string) ([]*Link, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner := scopedId(WorkspaceFrom(ctx), userId)
+	links := make([]*Link, 0, len(m.owners[owner]))
+	for code := range m.owners[owner] {
+		entry, ok := m.lookup(code)
+		if !ok {
+			continue
+		}
+		link := entry.link
+		links = append(links, &link)
+	}
+	return links, nil
+}
+
+func (m *MemoryStore) Hit(ctx context.Context, link *Link) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code := scopedId(WorkspaceFrom(ctx), link.Code)
+	entry, ok := m.lookup(code)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if entry.link.MaxClicks > 0 && entry.link.Clicks >= entry.link.MaxClicks {
+		return entry.link.Clicks, ErrExhausted
+	}
+	entry.link.Clicks++
+	m.links[code] = entry
+	return entry.link.Clicks, nil
+}
+
+func (m *MemoryStore) Find(ctx context.Context, query LinkQuery) (*LinkPage, error) {
+	links, err := m.List(ctx, query.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return paginate(links, query)
+}
+
+func (m *MemoryStore) IncrementCounters(ctx context.Context, code string, day time.Time, deltas map[string]int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code = scopedId(WorkspaceFrom(ctx), code)
+	key := day.UTC().Format(DayLayout)
+	if m.stats[code] == nil {
+		m.stats[code] = map[string]map[string]int64{}
+	}
+	if m.stats[code][key] == nil {
+		m.stats[code][key] = map[string]int64{}
+	}
+	for field, delta := range deltas {
+		m.stats[code][key][field] += delta
+	}
+	return nil
+}
+
+func (m *MemoryStore) Counters(ctx context.Context, code string, from, to time.Time) ([]DailyCounters, error) {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return nil, err
+	}
+	code = scopedId(WorkspaceFrom(ctx), code)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counters := []DailyCounters{}
+	for _, day := range days {
+		values, ok := m.stats[code][day]
+		if !ok {
+			continue
+		}
+		counters = append(counters, DailyCounters{Day: day, Counters: maps.Clone(values)})
+	}
+	return counters, nil
+}
+
+func (m *MemoryStore) AddVisitors(ctx context.Context, code string, day time.Time, visitors []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code = scopedId(WorkspaceFrom(ctx), code)
+	key := day.UTC().Format(DayLayout)
+	if m.visitors[code] == nil {
+		m.visitors[code] = map[string]*hll.Sketch{}
+	}
+	if m.visitors[code][key] == nil {
+		m.visitors[code][key] = hll.New()
+	}
+	for _, visitor := range visitors {
+		m.visitors[code][key].Add(visitor)
+	}
+	return nil
+}
+
+func (m *MemoryStore) CountVisitors(ctx context.Context, code string, from, to time.Time) (int64, error) {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return 0, err
+	}
+	code = scopedId(WorkspaceFrom(ctx), code)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	union := hll.New()
+	for _, day := range days {
+		if sketch, ok := m.visitors[code][day]; ok {
+			union.Merge(sketch)
+		}
+	}
+	return union.Count(), nil
+}
+
+func (m *MemoryStore) AppendClicks(ctx context.Context, clicks []ClickRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	workspace := WorkspaceFrom(ctx)
+	for _, click := range clicks {
+		code := scopedId(workspace, click.Code)
+		m.clickLog[code] = append(m.clickLog[code], click)
+	}
+	return nil
+}
+
+func (m *MemoryStore) ScanClicks(ctx context.Context, code string, from, to time.Time, fn func(ClickRecord) error) error {
+	start, end, err := clickLogRange(from, to)
+	if err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	clicks := m.clickLog[scopedId(WorkspaceFrom(ctx), code)]
+	m.mu.RUnlock()
+
+	// fn runs without the lock, a slow export must not hold back the writers
+	for _, click := range clicks {
+		if click.Time.Before(start) || !click.Time.Before(end) {
+			continue
+		}
+		if err := fn(click); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) SaveKey(_ context.Context, key *APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.keys[key.Id]; ok {
+		return ErrConflict
+	}
+	m.keys[key.Id] = *key
+	return nil
+}
+
+func (m *MemoryStore) GetKey(_ context.Context, id string) (*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &key, nil
+}
+
+func (m *MemoryStore) RevokeKey(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+		m.keys[id] = key
+	}
+	return nil
+}
+
+func (m *MemoryStore) TouchKey(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	key.LastUsedAt = &at
+	m.keys[id] = key
+	return nil
+}
+
+func (m *MemoryStore) NextSequence(ctx context.Context) (uint64, error) {
+	m.mu.Lock()
+	workspace := WorkspaceFrom(ctx)
+	sequence, ok := m.sequences[workspace]
+	if !ok {
+		sequence = &atomic.Uint64{}
+		m.sequences[workspace] = sequence
+	}
+	m.mu.Unlock()
+	return sequence.Add(1), nil
+}
+
+// Purge evicts the expired links that were not looked up since and drops the analytics older than StatsRetention
+func (m *MemoryStore) Purge(_ context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range m.links {
+		if !entry.evictAt.IsZero() && !now.Before(entry.evictAt) {
+			m.remove(entry)
+		}
+	}
+	cutoff := statsCutoff(now)
+	first := cutoff.Format(DayLayout)
+	for code, days := range m.stats {
+		maps.DeleteFunc(days, func(day string, _ map[string]int64) bool { return day < first })
+		if len(days) == 0 {
+			delete(m.stats, code)
+		}
+	}
+	for code, days := range m.visitors {
+		maps.DeleteFunc(days, func(day string, _ *hll.Sketch) bool { return day < first })
+		if len(days) == 0 {
+			delete(m.visitors, code)
+		}
+	}
+	for code, clicks := range m.clickLog {
+		kept := slices.Clone(clicks)
+		clicks = slices.DeleteFunc(kept, func(click ClickRecord) bool { return click.Time.Before(cutoff) })
+		if len(clicks) == 0 {
+			delete(m.clickLog, code)
+			continue
+		}
+		m.clickLog[code] = clicks
+	}
+	return nil
+}
+
+// lookup returns the live entry for the scoped code, evicting it when expired. Callers must hold the write lock
+func (m *MemoryStore) lookup(code string) (memoryEntry, bool) {
+	entry, ok := m.links[code]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if m.expired(entry) {
+		m.remove(entry)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+func (m *MemoryStore) remove(entry memoryEntry) {
+	code := scopedId(entry.workspace, entry.link.Code)
+	delete(m.links, code)
+	delete(m.stats, code)
+	delete(m.visitors, code)
+	delete(m.clickLog, code)
+	m.unindex(scopedId(entry.workspace, entry.link.UserId), code)
+}
+
+func (m *MemoryStore) index(userId, code string) {
+	if m.owners[userId] == nil {
+		m.owners[userId] = map[string]struct{}{}
+	}
+	m.owners[userId][code] = struct{}{}
+}
+
+func (m *MemoryStore) unindex(userId, code string) {
+	delete(m.owners[userId], code)
+	if len(m.owners[userId]) == 0 {
+		delete(m.owners, userId)
+	}
+}
+
+func (m *MemoryStore) entry(workspace string, link *Link) memoryEntry {
+	entry := memoryEntry{link: *link, workspace: workspace}
+	if ttl := retention(link, m.now()); ttl > 0 {
+		entry.evictAt = m.now().Add(ttl)
+	}
+	return entry
+}
+
+func (m *MemoryStore) expired(entry memoryEntry) bool {
+	return !entry.evictAt.IsZero() && !m.now().Before(entry.evictAt)
+}
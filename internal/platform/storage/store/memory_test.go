@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreSaveAndGet(t *testing.T) {
+	m := NewMemoryStore()
+	ctx := context.Background()
+
+	require.NoError(t, m.Save(ctx, &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+
+	got, err := m.Get(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", got.LongURL)
+
+	_, err = m.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStoreEvictsExpiredLinksAfterRetention(t *testing.T) {
+	m := NewMemoryStore()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	expiresAt := now.Add(time.Hour)
+	require.NoError(t, m.Save(ctx, &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1", ExpiresAt: &expiresAt}))
+	require.NoError(t, m.Save(ctx, &Link{Code: "forever", LongURL: "https://example.com", UserId: "1"}))
+
+	// expired links are kept for reporting until the retention is over
+	now = now.Add(time.Hour + ExpiredRetention - time.Second)
+	got, err := m.Get(ctx, "abc123")
+	require.NoError(t, err)
+	assert.True(t, got.Expired(now))
+
+	now = now.Add(time.Second)
+	_, err = m.Get(ctx, "abc123")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	links, err := m.List(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "forever", links[0].Code)
+}
+
+func TestMemoryStorePurgesDataPastItsRetention(t *testing.T) {
+	m := NewMemoryStore()
+	now := time.Now().UTC()
+	m.now = func() time.Time { return now }
+	assertRetention(t, m, now)
+}
+
+func TestMemoryStoreDeleteAndList(t *testing.T) {
+	m := NewMemoryStore()
+	ctx := context.Background()
+
+	require.NoError(t, m.Save(ctx, &Link{Code: "first", LongURL: "https://example.com/1", UserId: "1"}))
+	require.NoError(t, m.Save(ctx, &Link{Code: "second", LongURL: "https://example.com/2", UserId: "1"}))
+	require.NoError(t, m.Save(ctx, &Link{Code: "other", LongURL: "https://example.com/3", UserId: "2"}))
+
+	require.NoError(t, m.Delete(ctx, "first"))
+	assert.ErrorIs(t, m.Delete(ctx, "first"), ErrNotFound)
+
+	links, err := m.List(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "second", links[0].Code)
+}
+
+func TestMemoryStoreIsSafeForConcurrentUse(t *testing.T) {
+	m := NewMemoryStore()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = m.Save(ctx, &Link{Code: "shared", LongURL: "https://example.com", UserId: "1"})
+			_, _ = m.Get(ctx, "shared")
+			_, _ = m.List(ctx, "1")
+		}()
+	}
+	wg.Wait()
+
+	links, err := m.List(ctx, "1")
+	require.NoError(t, err)
+	assert.Len(t, links, 1)
+}
+
+func TestMemoryStoreSaveReturnsConflictWhenCodeIsTaken(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, &Link{Code: "taken", LongURL: "https://example.com/1", UserId: "1"}))
+	assert.ErrorIs(t, s.Save(ctx, &Link{Code: "taken", LongURL: "https://example.com/2", UserId: "2"}), ErrConflict)
+
+	got, err := s.Get(ctx, "taken")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", got.LongURL)
+}
+
+func TestMemoryStoreNextSequenceIncrements(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	first, err := s.NextSequence(ctx)
+	require.NoError(t, err)
+	second, err := s.NextSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, first+1, second)
+}
+
+func TestMemoryStoreHitEnforcesMaxClicksUnderConcurrency(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	link := &Link{Code: "once", LongURL: "https://example.com", UserId: "1", MaxClicks: 3}
+	require.NoError(t, s.Save(ctx, link))
+
+	var wg sync.WaitGroup
+	var served atomic.Int64
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Hit(ctx, link); err == nil {
+				served.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(3), served.Load())
+	got, err := s.Get(ctx, "once")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), got.Clicks)
+	assert.Equal(t, int64(0), *got.RemainingClicks())
+
+	// updates never reset the counter
+	require.NoError(t, s.Update(ctx, &Link{Code: "once", LongURL: "https://example.com/2", UserId: "1", MaxClicks: 3}))
+	_, err = s.Hit(ctx, link)
+	assert.ErrorIs(t, err, ErrExhausted)
+}
+
+func TestMemoryStoreScanClicksLetsTheCallbackWrite(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	first := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 1)
+	require.NoError(t, s.AppendClicks(ctx, []ClickRecord{{Code: "abc123", Time: first}, {Code: "abc123", Time: second}}))
+
+	// the purge and the append would deadlock if the scan held the lock, and must not change the clicks it walks
+	var scanned []time.Time
+	require.NoError(t, s.ScanClicks(ctx, "abc123", first, second, func(click ClickRecord) error {
+		scanned = append(scanned, click.Time)
+		require.NoError(t, s.Purge(ctx, second.Add(StatsRetention)))
+		return s.AppendClicks(ctx, []ClickRecord{{Code: "other1", Time: second}})
+	}))
+	assert.Equal(t, []time.Time{first, second}, scanned)
+	assertClickCount(t, s, ctx, "abc123", first, 0)
+	assertClickCount(t, s, ctx, "abc123", second, 1)
+	assertClickCount(t, s, ctx, "other1", second, 2)
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	SortCreated = "created"
+	SortClicks  = "clicks"
+
+	StatusActive  = "active"
+	StatusExpired = "expired"
+
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// LinkQuery selects a page of the links owned by UserId, newest or most clicked first
+type LinkQuery struct {
+	UserId string
+	// Sort is SortCreated or SortClicks, SortCreated when empty
+	Sort string
+	// Tag, Status and Domain filter the links when they are not empty. Status StatusExpired also matches links that
+	// reached their click limit
+	Tag    string
+	Status string
+	Domain string
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+	Limit  int
+}
+
+// LinkPage is a page of links, NextCursor is empty on the last page
+type LinkPage struct {
+	Links      []*Link `json:"links"`
+	NextCursor string  `json:"next_cursor"`
+}
+
+// cursor is the sort key of the last link of a page
+type cursor struct {
+	CreatedAt time.Time `json:"t"`
+	Clicks    int64     `json:"n"`
+	Code      string    `json:"c"`
+}
+
+// Active reports whether the link still redirects
+func (l *Link) Active(now time.Time) bool {
+	return !l.Expired(now) && (l.MaxClicks == 0 || l.Clicks < l.MaxClicks)
+}
+
+// validate normalizes the query defaults and decodes its cursor
+func (q *LinkQuery) validate() (*cursor, error) {
+	if q.Sort == "" {
+		q.Sort = SortCreated
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Sort != SortCreated && q.Sort != SortClicks || q.Limit < 0 || q.Limit > MaxPageSize ||
+		q.Status != "" && q.Status != StatusActive && q.Status != StatusExpired {
+		return nil, ErrInvalidQuery
+	}
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidQuery
+	}
+	var after cursor
+	if err := json.Unmarshal(data, &after); err != nil {
+		return nil, ErrInvalidQuery
+	}
+	return &after, nil
+}
+
+// compare orders by the sort key descending, ties are broken by code descending
+func (q *LinkQuery) compare(a, b cursor) int {
+	var c int
+	if q.Sort == SortClicks {
+		c = cmp.Compare(b.Clicks, a.Clicks)
+	} else {
+		c = b.CreatedAt.Compare(a.CreatedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(b.Code, a.Code)
+}
+
+func (q *LinkQuery) matches(link *Link, now time.Time) bool {
+	if q.Tag != "" && !slices.Contains(link.Tags, q.Tag) {
+		return false
+	}
+	if q.Status != "" && link.Active(now) != (q.Status == StatusActive) {
+		return false
+	}
+	if q.Domain != "" {
+		parsed, err := url.Parse(link.LongURL)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(parsed.Hostname())
+		domain := strings.ToLower(q.Domain)
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+	return true
+}
+
+func keyOf(link *Link) cursor {
+	return cursor{CreatedAt: link.CreatedAt, Clicks: link.Clicks, Code: link.Code}
+}
+
+// pageBuilder collects the matching links of a page from links already sorted by the query order
+type pageBuilder struct {
+	query LinkQuery
+	now   time.Time
+	page  LinkPage
+}
+
+func newPageBuilder(query LinkQuery) *pageBuilder {
+	return &pageBuilder{query: query, now: time.Now(), page: LinkPage{Links: []*Link{}}}
+}
+
+// add appends the link when it matches and reports whether the page is complete
+func (b *pageBuilder) add(link *Link) bool {
+	if !b.query.matches(link, b.now) {
+		return false
+	}
+	if len(b.page.Links) == b.query.Limit {
+		data, _ := json.Marshal(keyOf(b.page.Links[len(b.page.Links)-1]))
+		b.page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+		return true
+	}
+	b.page.Links = append(b.page.Links, link)
+	return false
+}
+
+// paginate sorts the owner links in memory, used by the backends whose owner index is not ordered
+func paginate(links []*Link, query LinkQuery) (*LinkPage, error) {
+	after, err := query.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(links, func(a, b *Link) int {
+		return query.compare(keyOf(a), keyOf(b))
+	})
+	builder := newPageBuilder(query)
+	for _, link := range links {
+		if after != nil && query.compare(keyOf(link), *after) <= 0 {
+			continue
+		}
+		if builder.add(link) {
+			break
+		}
+	}
+	return &builder.page, nil
+}
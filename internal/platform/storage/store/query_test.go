@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveQueryFixtures stores five links of user "1", created one minute apart, and one link of user "2"
+func saveQueryFixtures(t *testing.T, repo LinkRepository) {
+	ctx := context.Background()
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	expired := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		link := &Link{
+			Code:      fmt.Sprintf("link%d", i),
+			LongURL:   fmt.Sprintf("https://docs.example.com/%d", i),
+			UserId:    "1",
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+			Tags:      []string{"docs"},
+		}
+		if i%2 == 1 {
+			link.LongURL = fmt.Sprintf("https://other.test/%d", i)
+			link.Tags = []string{"campaign"}
+		}
+		if i == 4 {
+			link.ExpiresAt = &expired
+		}
+		require.NoError(t, repo.Save(ctx, link))
+		for hit := 0; hit < 5-i; hit++ {
+			_, err := repo.Hit(ctx, link)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, repo.Save(ctx, &Link{Code: "foreign", LongURL: "https://docs.example.com", UserId: "2", CreatedAt: created}))
+}
+
+func codes(page *LinkPage) []string {
+	result := make([]string, 0, len(page.Links))
+	for _, link := range page.Links {
+		result = append(result, link.Code)
+	}
+	return result
+}
+
+// assertFindPagesAndFilters runs the same Find scenarios against any backend
+func assertFindPagesAndFilters(t *testing.T, repo LinkRepository) {
+	ctx := context.Background()
+	saveQueryFixtures(t, repo)
+
+	first, err := repo.Find(ctx, LinkQuery{UserId: "1", Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"link4", "link3"}, codes(first))
+	require.NotEmpty(t, first.NextCursor)
+
+	second, err := repo.Find(ctx, LinkQuery{UserId: "1", Limit: 2, Cursor: first.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"link2", "link1"}, codes(second))
+
+	last, err := repo.Find(ctx, LinkQuery{UserId: "1", Limit: 2, Cursor: second.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"link0"}, codes(last))
+	assert.Empty(t, last.NextCursor)
+
+	byClicks, err := repo.Find(ctx, LinkQuery{UserId: "1", Sort: SortClicks, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"link0", "link1", "link2"}, codes(byClicks))
+	byClicks, err = repo.Find(ctx, LinkQuery{UserId: "1", Sort: SortClicks, Cursor: byClicks.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"link3", "link4"}, codes(byClicks))
+
+	tagged, err := repo.Find(ctx, LinkQuery{UserId: "1", Tag: "campaign"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"link3", "link1"}, codes(tagged))
+
+	expired, err := repo.Find(ctx, LinkQuery{UserId: "1", Status: StatusExpired})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"link4"}, codes(expired))
+
+	active, err := repo.Find(ctx, LinkQuery{UserId: "1", Status: StatusActive, Domain: "example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"link2", "link0"}, codes(active))
+
+	for _, query := range []LinkQuery{
+		{UserId: "1", Sort: "name"},
+		{UserId: "1", Status: "deleted"},
+		{UserId: "1", Limit: MaxPageSize + 1},
+		{UserId: "1", Cursor: "not a cursor"},
+	} {
+		_, err := repo.Find(ctx, query)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	}
+}
+
+func TestMemoryStoreFind(t *testing.T) {
+	assertFindPagesAndFilters(t, NewMemoryStore())
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alexperezortuno/go-url-shortner/internal/config"
+	"log"
+	"time"
+)
+
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+	DriverBolt   = "bolt"
+	DriverSQLite = "sqlite"
+)
+
+// ExpiredRetention is how long the backends keep a link after it expires, so its metadata can still be reported.
+// Redis and memory evict the link on time, bolt and sqlite on their next Purge
+const ExpiredRetention = 30 * 24 * time.Hour
+
+var (
+	// ErrNotFound is returned when no link is stored under the requested code
+	ErrNotFound = errors.New("link not found")
+	// ErrConflict is returned when the requested code is already taken by another link
+	ErrConflict = errors.New("link already exists")
+	// ErrUnavailable is returned when the storage backend cannot be reached or fails
+	ErrUnavailable = errors.New("storage unavailable")
+	// ErrExhausted is returned by Hit when the link already reached its MaxClicks
+	ErrExhausted = errors.New("link reached its click limit")
+	// ErrInvalidQuery is returned by Find for an unknown sort or status, a limit out of range or a malformed cursor
+	ErrInvalidQuery = errors.New("invalid link query")
+)
+
+// Link is the mapping between a short code and its original URL
+type Link struct {
+	Code      string            `json:"code"`
+	LongURL   string            `json:"long_url"`
+	UserId    string            `json:"user_id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	// RedirectStatus overrides the configured redirect status for this link when it is not zero
+	RedirectStatus int `json:"redirect_status,omitempty"`
+	// ExpiresAt is nil for links that never expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// MaxClicks is the number of redirects allowed, zero means unlimited
+	MaxClicks int64 `json:"max_clicks,omitempty"`
+	// Clicks counts the redirects served, only Hit changes it
+	Clicks int64 `json:"clicks"`
+}
+
+// RemainingClicks returns how many redirects are left, nil for links without a click limit
+func (l *Link) RemainingClicks() *int64 {
+	if l.MaxClicks == 0 {
+		return nil
+	}
+	remaining := max(l.MaxClicks-l.Clicks, 0)
+	return &remaining
+}
+
+// Expired reports whether the link is past its expiration time
+func (l *Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// retention returns how long a backend that evicts keys must keep the link, zero means forever
+func retention(link *Link, now time.Time) time.Duration {
+	if link.ExpiresAt == nil {
+		return 0
+	}
+	return max(link.ExpiresAt.Sub(now)+ExpiredRetention, time.Second)
+}
+
+// LinkRepository is the storage contract used by the handlers, every backend implements it. Every operation acts on
+// the workspace of its context, see WithWorkspace
+type LinkRepository interface {
+	// Save atomically reserves the link code and stores the link, or returns ErrConflict when the code is taken
+	Save(ctx context.Context, link *Link) error
+	// Get returns the link stored under code or ErrNotFound
+	Get(ctx context.Context, code string) (*Link, error)
+	// Update replaces an existing link, keeping its Clicks, or returns ErrNotFound
+	Update(ctx context.Context, link *Link) error
+	// Delete removes the link stored under code or returns ErrNotFound
+	Delete(ctx context.Context, code string) error
+	// List returns the links owned by userId
+	List(ctx context.Context, userId string) ([]*Link, error)
+	// Find returns a page of the links owned by query.UserId or ErrInvalidQuery
+	Find(ctx context.Context, query LinkQuery) (*LinkPage, error)
+	// Hit atomically counts a redirect of the link and returns the clicks counted so far, or ErrExhausted when the
+	// link already reached its MaxClicks
+	Hit(ctx context.Context, link *Link) (int64, error)
+	// NextSequence atomically increments and returns the counter used by the counter code generator
+	NextSequence(ctx context.Context) (uint64, error)
+}
+
+// New returns the Repository selected by cfg.StorageDriver
+func New(cfg *config.Config) (Repository, error) {
+	switch cfg.StorageDriver {
+	case DriverRedis:
+		return InitializeStore(cfg), nil
+	case DriverMemory:
+		return NewMemoryStore(), nil
+	case DriverBolt:
+		return NewBoltStore(cfg.BoltPath)
+	case DriverSQLite:
+		return initializeSQLStore(cfg)
+	default:
+		return nil, fmt.Errorf("invalid storage driver: %s", cfg.StorageDriver)
+	}
+}
+
+// storageError keeps the typed errors as they are and reports any other failure as ErrUnavailable
+func storageError(err error) error {
+	for _, typed := range []error{ErrNotFound, ErrConflict, ErrUnavailable, ErrExhausted, ErrInvalidQuery} {
+		if errors.Is(err, typed) {
+			return err
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
+
+func initializeSQLStore(cfg *config.Config) (*SQLStore, error) {
+	s, err := NewSQLStore(cfg.SQLDsn)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.SQLMigrate {
+		return s, nil
+	}
+
+	applied, err := s.Migrate(context.Background())
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	log.Printf("Database migrated successfully: %d migrations applied", applied)
+	return s, nil
+}
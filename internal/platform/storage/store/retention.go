@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PurgeInterval is how often Sweep drops the data past its retention
+const PurgeInterval = time.Hour
+
+// Purger is implemented by the backends that can not expire their data by themselves, Redis expires its keys
+type Purger interface {
+	// Purge deletes the links expired for more than ExpiredRetention, with their analytics, and the counters, visitor
+	// sketches and raw clicks older than StatsRetention in every workspace
+	Purge(ctx context.Context, now time.Time) error
+}
+
+// Sweep purges repo right away and then every interval until ctx is done, it returns at once when repo is not a
+// Purger. Failed purges are logged and retried on the next interval
+func Sweep(ctx context.Context, repo any, interval time.Duration) {
+	purger, ok := repo.(Purger)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := purger.Purge(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to purge expired data | Error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evictionCutoff returns the latest expiration time of the links to evict
+func evictionCutoff(now time.Time) time.Time {
+	return now.Add(-ExpiredRetention).UTC()
+}
+
+// statsCutoff returns the first UTC day whose analytics are kept
+func statsCutoff(now time.Time) time.Time {
+	return now.Add(-StatsRetention).UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertRetention runs the same Purge scenario against any backend, now is the time the links are saved at
+func assertRetention(t *testing.T, repo Repository, now time.Time) {
+	ctx := context.Background()
+	acme := WithWorkspace(ctx, "acme")
+	evicted := now.Add(-ExpiredRetention - time.Hour)
+	expired := now.Add(-time.Hour)
+	require.NoError(t, repo.Save(acme, &Link{Code: "old", LongURL: "https://example.com/old", UserId: "1",
+		CreatedAt: evicted, ExpiresAt: &evicted}))
+	require.NoError(t, repo.Save(ctx, &Link{Code: "expired", LongURL: "https://example.com/expired", UserId: "1",
+		CreatedAt: expired, ExpiresAt: &expired}))
+	require.NoError(t, repo.Save(ctx, &Link{Code: "forever", LongURL: "https://example.com", UserId: "1",
+		CreatedAt: now}))
+
+	stale := now.Add(-StatsRetention - 48*time.Hour)
+	for _, day := range []time.Time{stale, now} {
+		require.NoError(t, repo.IncrementCounters(ctx, "forever", day, map[string]int64{"total": 1}))
+		require.NoError(t, repo.AddVisitors(ctx, "forever", day, []string{"a"}))
+		require.NoError(t, repo.AppendClicks(ctx, []ClickRecord{{Code: "forever", Time: day, Data: json.RawMessage(`{}`)}}))
+	}
+	require.NoError(t, repo.IncrementCounters(acme, "old", now, map[string]int64{"total": 1}))
+	require.NoError(t, repo.AppendClicks(acme, []ClickRecord{{Code: "old", Time: now, Data: json.RawMessage(`{}`)}}))
+
+	// the memory backend keeps the links saved past their retention for a second
+	purger, ok := repo.(Purger)
+	require.True(t, ok)
+	require.NoError(t, purger.Purge(ctx, now.Add(time.Minute)))
+
+	_, err := repo.Get(acme, "old")
+	assert.ErrorIs(t, err, ErrNotFound)
+	links, err := repo.List(acme, "1")
+	require.NoError(t, err)
+	assert.Empty(t, links)
+	counters, err := repo.Counters(acme, "old", now, now)
+	require.NoError(t, err)
+	assert.Empty(t, counters)
+	assertClickCount(t, repo, acme, "old", now, 0)
+
+	// the expired links are still reported until their retention is over
+	_, err = repo.Get(ctx, "expired")
+	require.NoError(t, err)
+
+	counters, err = repo.Counters(ctx, "forever", stale, stale)
+	require.NoError(t, err)
+	assert.Empty(t, counters)
+	counters, err = repo.Counters(ctx, "forever", now, now)
+	require.NoError(t, err)
+	assert.Len(t, counters, 1)
+	visitors, err := repo.CountVisitors(ctx, "forever", stale, stale)
+	require.NoError(t, err)
+	assert.Zero(t, visitors)
+	visitors, err = repo.CountVisitors(ctx, "forever", now, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), visitors)
+	assertClickCount(t, repo, ctx, "forever", stale, 0)
+	assertClickCount(t, repo, ctx, "forever", now, 1)
+}
+
+// assertClickCount checks how many raw clicks the link has on the UTC day of day
+func assertClickCount(t *testing.T, repo Repository, ctx context.Context, code string, day time.Time, expected int) {
+	count := 0
+	require.NoError(t, repo.ScanClicks(ctx, code, day, day, func(ClickRecord) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, expected, count)
+}
+
+func TestSweepReturnsWhenTheBackendExpiresItsData(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		Sweep(context.Background(), struct{}{}, time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Sweep did not return")
+	}
+}
@@ -0,0 +1,581 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/hll"
+	"github.com/alexperezortuno/go-url-shortner/internal/platform/storage/migrations"
+	_ "modernc.org/sqlite"
+	"time"
+)
+
+const linkColumns = "code, long_url, user_id, created_at, metadata, redirect_status, expires_at, max_clicks, clicks, tags"
+
+// SQLStore is a LinkRepository persisted in a SQLite database, the schema is managed by the migrations package. The
+// rows of the links and their stats carry the workspace they belong to
+type SQLStore struct {
+	db *sql.DB
+}
+
+var (
+	_ Repository = (*SQLStore)(nil)
+	_ Purger     = (*SQLStore)(nil)
+)
+
+// NewSQLStore opens the SQLite database described by dsn, the schema must be migrated before use
+func NewSQLStore(dsn string) (*SQLStore, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, serializing the connections avoids SQLITE_BUSY errors
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to open database %s: %w", dsn, err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Migrate applies the pending schema migrations and returns how many were applied
+func (s *SQLStore) Migrate(ctx context.Context) (int, error) {
+	return migrations.Run(ctx, s.db)
+}
+
+// Close closes the underlying database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) Save(ctx context.Context, link *Link) error {
+	metadata, err := json.Marshal(link.Metadata)
+	if err != nil {
+		return err
+	}
+	tags, err := json.Marshal(link.Tags)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx, `INSERT INTO links (`+linkColumns+`, workspace)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (workspace, code) DO NOTHING`,
+		link.Code, link.LongURL, link.UserId, link.CreatedAt, string(metadata), link.RedirectStatus, link.ExpiresAt,
+		link.MaxClicks, link.Clicks, string(tags), WorkspaceFrom(ctx))
+	if err != nil {
+		return storageError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+	if affected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, code string) (*Link, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+linkColumns+" FROM links WHERE workspace = $1 AND code = $2",
+		WorkspaceFrom(ctx), code)
+	link, err := scanLink(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return link, nil
+}
+
+func (s *SQLStore) Update(ctx context.Context, link *Link) error {
+	metadata, err := json.Marshal(link.Metadata)
+	if err != nil {
+		return err
+	}
+	tags, err := json.Marshal(link.Tags)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx, `UPDATE links
+		SET long_url = $1, user_id = $2, metadata = $3, redirect_status = $4, expires_at = $5, max_clicks = $6,
+			tags = $7
+		WHERE workspace = $8 AND code = $9`,
+		link.LongURL, link.UserId, string(metadata), link.RedirectStatus, link.ExpiresAt, link.MaxClicks, string(tags),
+		WorkspaceFrom(ctx), link.Code)
+	if err != nil {
+		return storageError(err)
+	}
+	return expectAffected(result)
+}
+
+func (s *SQLStore) Delete(ctx context.Context, code string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storageError(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	workspace := WorkspaceFrom(ctx)
+	result, err := tx.ExecContext(ctx, "DELETE FROM links WHERE workspace = $1 AND code = $2", workspace, code)
+	if err != nil {
+		return storageError(err)
+	}
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+	for _, table := range []string{"link_stats", "link_sketches", "click_log"} {
+		_, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE workspace = $1 AND code = $2", workspace, code)
+		if err != nil {
+			return storageError(err)
+		}
+	}
+	return storageError(tx.Commit())
+}
+
+func (s *SQLStore) List(ctx context.Context, userId string) ([]*Link, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+linkColumns+` FROM links WHERE workspace = $1 AND user_id = $2
+		ORDER BY created_at, code`, WorkspaceFrom(ctx), userId)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	links := []*Link{}
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, storageError(err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageError(err)
+	}
+	return links, nil
+}
+
+// Find walks the owner index in the query order from the cursor, the filters are applied while the rows are read
+func (s *SQLStore) Find(ctx context.Context, query LinkQuery) (*LinkPage, error) {
+	after, err := query.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	column := "created_at"
+	var value any
+	if after != nil {
+		value = after.CreatedAt
+	}
+	if query.Sort == SortClicks {
+		column = "clicks"
+		if after != nil {
+			value = after.Clicks
+		}
+	}
+	statement := "SELECT " + linkColumns + " FROM links WHERE workspace = $1 AND user_id = $2"
+	args := []any{WorkspaceFrom(ctx), query.UserId}
+	if after != nil {
+		statement += fmt.Sprintf(" AND (%[1]s < $3 OR (%[1]s = $3 AND code < $4))", column)
+		args = append(args, value, after.Code)
+	}
+	statement += fmt.Sprintf(" ORDER BY %s DESC, code DESC", column)
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	builder := newPageBuilder(query)
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, storageError(err)
+		}
+		if builder.add(link) {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageError(err)
+	}
+	return &builder.page, nil
+}
+
+func (s *SQLStore) Hit(ctx context.Context, link *Link) (int64, error) {
+	workspace := WorkspaceFrom(ctx)
+	var clicks int64
+	err := s.db.QueryRowContext(ctx, `UPDATE links SET clicks = clicks + 1
+		WHERE workspace = $1 AND code = $2 AND (max_clicks = 0 OR clicks < max_clicks)
+		RETURNING clicks`, workspace, link.Code).Scan(&clicks)
+	if err == nil {
+		return clicks, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, storageError(err)
+	}
+
+	// nothing was updated, either the link is gone or it reached its limit
+	err = s.db.QueryRowContext(ctx, "SELECT clicks FROM links WHERE workspace = $1 AND code = $2", workspace, link.Code).
+		Scan(&clicks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return clicks, ErrExhausted
+}
+
+func (s *SQLStore) IncrementCounters(ctx context.Context, code string, day time.Time, deltas map[string]int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storageError(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for field, delta := range deltas {
+		_, err := tx.ExecContext(ctx, `INSERT INTO link_stats (workspace, code, day, field, value)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (workspace, code, day, field) DO UPDATE SET value = value + excluded.value`,
+			WorkspaceFrom(ctx), code, day.UTC().Format(DayLayout), field, delta)
+		if err != nil {
+			return storageError(err)
+		}
+	}
+	return storageError(tx.Commit())
+}
+
+func (s *SQLStore) Counters(ctx context.Context, code string, from, to time.Time) ([]DailyCounters, error) {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT day, field, value FROM link_stats
+		WHERE workspace = $1 AND code = $2 AND day >= $3 AND day <= $4
+		ORDER BY day`, WorkspaceFrom(ctx), code, days[0], days[len(days)-1])
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	counters := []DailyCounters{}
+	for rows.Next() {
+		var day, field string
+		var value int64
+		if err := rows.Scan(&day, &field, &value); err != nil {
+			return nil, storageError(err)
+		}
+		if len(counters) == 0 || counters[len(counters)-1].Day != day {
+			counters = append(counters, DailyCounters{Day: day, Counters: map[string]int64{}})
+		}
+		counters[len(counters)-1].Counters[field] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageError(err)
+	}
+	return counters, nil
+}
+
+func (s *SQLStore) AddVisitors(ctx context.Context, code string, day time.Time, visitors []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storageError(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	sketch := hll.New()
+	var data []byte
+	err = tx.QueryRowContext(ctx, "SELECT sketch FROM link_sketches WHERE workspace = $1 AND code = $2 AND day = $3",
+		WorkspaceFrom(ctx), code, day.UTC().Format(DayLayout)).Scan(&data)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return storageError(err)
+	}
+	if data != nil {
+		if err := sketch.UnmarshalBinary(data); err != nil {
+			return err
+		}
+	}
+	for _, visitor := range visitors {
+		sketch.Add(visitor)
+	}
+
+	if data, err = sketch.MarshalBinary(); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO link_sketches (workspace, code, day, sketch) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (workspace, code, day) DO UPDATE SET sketch = excluded.sketch`,
+		WorkspaceFrom(ctx), code, day.UTC().Format(DayLayout), data)
+	if err != nil {
+		return storageError(err)
+	}
+	return storageError(tx.Commit())
+}
+
+func (s *SQLStore) AppendClicks(ctx context.Context, clicks []ClickRecord) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storageError(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	workspace := WorkspaceFrom(ctx)
+	for _, click := range clicks {
+		_, err := tx.ExecContext(ctx, "INSERT INTO click_log (workspace, code, time, data) VALUES ($1, $2, $3, $4)",
+			workspace, click.Code, click.Time.UnixNano(), string(click.Data))
+		if err != nil {
+			return storageError(err)
+		}
+	}
+	return storageError(tx.Commit())
+}
+
+func (s *SQLStore) ScanClicks(ctx context.Context, code string, from, to time.Time, fn func(ClickRecord) error) error {
+	start, end, err := clickLogRange(from, to)
+	if err != nil {
+		return err
+	}
+
+	// the log is read in keyset pages so no query keeps the database busy while fn runs
+	lastTime, lastId := start.UnixNano(), int64(0)
+	for {
+		page, err := s.clickLogPage(ctx, code, lastTime, lastId, end.UnixNano())
+		if err != nil {
+			return err
+		}
+		for _, row := range page {
+			if err := fn(row.click); err != nil {
+				return err
+			}
+			lastTime, lastId = row.click.Time.UnixNano(), row.id
+		}
+		if len(page) < clickLogPageSize {
+			return nil
+		}
+	}
+}
+
+type clickLogRow struct {
+	id    int64
+	click ClickRecord
+}
+
+// clickLogPage reads the clicks of code after (lastTime, lastId) and before end
+func (s *SQLStore) clickLogPage(ctx context.Context, code string, lastTime, lastId, end int64) ([]clickLogRow, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, time, data FROM click_log
+		WHERE workspace = $1 AND code = $2 AND (time > $3 OR (time = $3 AND id > $4)) AND time < $5
+		ORDER BY time, id LIMIT $6`, WorkspaceFrom(ctx), code, lastTime, lastId, end, clickLogPageSize)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	var page []clickLogRow
+	for rows.Next() {
+		var row clickLogRow
+		var nanos int64
+		var data string
+		if err := rows.Scan(&row.id, &nanos, &data); err != nil {
+			return nil, storageError(err)
+		}
+		row.click = ClickRecord{Code: code, Time: time.Unix(0, nanos).UTC(), Data: json.RawMessage(data)}
+		page = append(page, row)
+	}
+	return page, storageError(rows.Err())
+}
+
+func (s *SQLStore) CountVisitors(ctx context.Context, code string, from, to time.Time) (int64, error) {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT sketch FROM link_sketches
+		WHERE workspace = $1 AND code = $2 AND day >= $3 AND day <= $4`, WorkspaceFrom(ctx), code, days[0],
+		days[len(days)-1])
+	if err != nil {
+		return 0, storageError(err)
+	}
+	defer rows.Close()
+
+	union := hll.New()
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return 0, storageError(err)
+		}
+		sketch := hll.New()
+		if err := sketch.UnmarshalBinary(data); err != nil {
+			return 0, err
+		}
+		union.Merge(sketch)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, storageError(err)
+	}
+	return union.Count(), nil
+}
+
+// Purge deletes the expired links and the analytics past their retention in a single transaction
+func (s *SQLStore) Purge(ctx context.Context, now time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storageError(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	expired := evictionCutoff(now)
+	for _, table := range []string{"link_stats", "link_sketches", "click_log"} {
+		_, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE (workspace, code) IN
+			(SELECT workspace, code FROM links WHERE expires_at <= $1)`, expired)
+		if err != nil {
+			return storageError(err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM links WHERE expires_at <= $1", expired); err != nil {
+		return storageError(err)
+	}
+
+	cutoff := statsCutoff(now)
+	for _, table := range []string{"link_stats", "link_sketches"} {
+		_, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE day < $1", cutoff.Format(DayLayout))
+		if err != nil {
+			return storageError(err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM click_log WHERE time < $1", cutoff.UnixNano()); err != nil {
+		return storageError(err)
+	}
+	return storageError(tx.Commit())
+}
+
+// NextSequence counts with the codes sequence in the default workspace and with a codes:<workspace> sequence, created
+// on first use, in the others
+func (s *SQLStore) NextSequence(ctx context.Context) (uint64, error) {
+	name := "codes"
+	if workspace := WorkspaceFrom(ctx); workspace != DefaultWorkspace {
+		name += ":" + workspace
+	}
+	var value uint64
+	err := s.db.QueryRowContext(ctx, `INSERT INTO sequences (name, value) VALUES ($1, 1)
+		ON CONFLICT (name) DO UPDATE SET value = value + 1
+		RETURNING value`, name).Scan(&value)
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return value, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (s *SQLStore) SaveKey(ctx context.Context, key *APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx, `INSERT INTO api_keys (id, hash, user_id, workspace, name, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO NOTHING`,
+		key.Id, key.Hash, key.UserId, key.Workspace, key.Name, string(scopes), key.CreatedAt)
+	if err != nil {
+		return storageError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+	if affected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *SQLStore) GetKey(ctx context.Context, id string) (*APIKey, error) {
+	var key APIKey
+	var scopes string
+	var lastUsedAt, revokedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT id, hash, user_id, workspace, name, scopes, created_at, last_used_at,
+			revoked_at
+		FROM api_keys WHERE id = $1`, id).
+		Scan(&key.Id, &key.Hash, &key.UserId, &key.Workspace, &key.Name, &scopes, &key.CreatedAt, &lastUsedAt,
+			&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+func (s *SQLStore) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2",
+		at, id)
+	if err != nil {
+		return storageError(err)
+	}
+	return expectAffected(result)
+}
+
+func (s *SQLStore) TouchKey(ctx context.Context, id string, at time.Time) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $1 WHERE id = $2", at, id)
+	if err != nil {
+		return storageError(err)
+	}
+	return expectAffected(result)
+}
+
+func scanLink(row scanner) (*Link, error) {
+	var link Link
+	var metadata, tags string
+	var expiresAt sql.NullTime
+	err := row.Scan(&link.Code, &link.LongURL, &link.UserId, &link.CreatedAt, &metadata, &link.RedirectStatus,
+		&expiresAt, &link.MaxClicks, &link.Clicks, &tags)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+	if err := json.Unmarshal([]byte(metadata), &link.Metadata); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &link.Tags); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLStore(t *testing.T) *SQLStore {
+	s, err := NewSQLStore(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	_, err = s.Migrate(context.Background())
+	require.NoError(t, err)
+	return s
+}
+
+func TestSQLStoreSaveAndGet(t *testing.T) {
+	s := newTestSQLStore(t)
+	ctx := context.Background()
+
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := created.Add(24 * time.Hour)
+	link := &Link{
+		Code:           "abc123",
+		LongURL:        "https://example.com",
+		UserId:         "1",
+		CreatedAt:      created,
+		Metadata:       map[string]string{"campaign": "launch"},
+		RedirectStatus: 302,
+		ExpiresAt:      &expiresAt,
+	}
+	require.NoError(t, s.Save(ctx, link))
+
+	got, err := s.Get(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", got.LongURL)
+	assert.True(t, created.Equal(got.CreatedAt))
+	assert.Equal(t, "launch", got.Metadata["campaign"])
+	assert.Equal(t, 302, got.RedirectStatus)
+	require.NotNil(t, got.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*got.ExpiresAt))
+
+	_, err = s.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLStoreUpdateDeleteAndList(t *testing.T) {
+	s := newTestSQLStore(t)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, s.Update(ctx, &Link{Code: "missing"}), ErrNotFound)
+
+	now := time.Now().UTC()
+	require.NoError(t, s.Save(ctx, &Link{Code: "first", LongURL: "https://example.com/1", UserId: "1", CreatedAt: now}))
+	require.NoError(t, s.Save(ctx, &Link{Code: "second", LongURL: "https://example.com/2", UserId: "1", CreatedAt: now.Add(time.Second)}))
+	require.NoError(t, s.Update(ctx, &Link{Code: "second", LongURL: "https://example.org/2", UserId: "1"}))
+
+	links, err := s.List(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	assert.Equal(t, "first", links[0].Code)
+	assert.Equal(t, "https://example.org/2", links[1].LongURL)
+
+	require.NoError(t, s.Delete(ctx, "first"))
+	assert.ErrorIs(t, s.Delete(ctx, "first"), ErrNotFound)
+
+	links, err = s.List(ctx, "1")
+	require.NoError(t, err)
+	assert.Len(t, links, 1)
+}
+
+func TestSQLStoreSaveReturnsConflictWhenCodeIsTaken(t *testing.T) {
+	s := newTestSQLStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, &Link{Code: "taken", LongURL: "https://example.com/1", UserId: "1"}))
+	assert.ErrorIs(t, s.Save(ctx, &Link{Code: "taken", LongURL: "https://example.com/2", UserId: "2"}), ErrConflict)
+
+	got, err := s.Get(ctx, "taken")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", got.LongURL)
+}
+
+func TestSQLStoreNextSequenceIncrements(t *testing.T) {
+	s := newTestSQLStore(t)
+	ctx := context.Background()
+
+	first, err := s.NextSequence(ctx)
+	require.NoError(t, err)
+	second, err := s.NextSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, first+1, second)
+}
+
+func TestSQLStoreHitEnforcesMaxClicks(t *testing.T) {
+	s := newTestSQLStore(t)
+	ctx := context.Background()
+	link := &Link{Code: "twice", LongURL: "https://example.com", UserId: "1", CreatedAt: time.Now().UTC(), MaxClicks: 2}
+	require.NoError(t, s.Save(ctx, link))
+
+	for want := int64(1); want <= 2; want++ {
+		clicks, err := s.Hit(ctx, link)
+		require.NoError(t, err)
+		assert.Equal(t, want, clicks)
+	}
+	_, err := s.Hit(ctx, link)
+	assert.ErrorIs(t, err, ErrExhausted)
+
+	got, err := s.Get(ctx, "twice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), got.Clicks)
+
+	_, err = s.Hit(ctx, &Link{Code: "missing"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLStoreFind(t *testing.T) {
+	assertFindPagesAndFilters(t, newTestSQLStore(t))
+}
+
+func TestSQLStoreClickAnalytics(t *testing.T) {
+	assertClickAnalytics(t, newTestSQLStore(t))
+	assertVisitorsUnionAcrossDays(t, newTestSQLStore(t))
+}
+
+func TestSQLStoreAPIKeys(t *testing.T) {
+	assertAPIKeys(t, newTestSQLStore(t))
+}
+
+func TestSQLStoreWorkspaces(t *testing.T) {
+	assertWorkspaces(t, newTestSQLStore(t))
+}
+
+func TestSQLStorePurgesDataPastItsRetention(t *testing.T) {
+	assertRetention(t, newTestSQLStore(t), time.Now().UTC())
+}
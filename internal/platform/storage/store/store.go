@@ -2,24 +2,22 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/alexperezortuno/go-url-shortner/internal/config"
 	"github.com/go-redis/redis/v9"
 	"log"
+	"strconv"
 	"time"
 )
 
-// StorageService is struct wrapper around raw Redis client
+// StorageService is the Redis backed LinkRepository
 type StorageService struct {
 	redisClient *redis.Client
 }
 
-// Top level declarations for the storeService and Redis context
-var (
-	storeService = &StorageService{}
-	ctx          = context.Background()
-)
-
-const CacheDuration = 6 * time.Hour
+var _ Repository = (*StorageService)(nil)
 
 // InitializeStore is initializing the store service and return a store pointer
 func InitializeStore(cfg *config.Config) *StorageService {
@@ -29,29 +27,456 @@ func InitializeStore(cfg *config.Config) *StorageService {
 		DB:       cfg.RedisDb,   // use default DB
 	})
 
-	pong, err := rdb.Ping(ctx).Result()
+	pong, err := rdb.Ping(context.Background()).Result()
 	if err != nil {
 		log.Printf("Error init Redis: %v", err)
 	}
 
 	log.Printf("Redis started successfully: pong message = {%s}", pong)
-	storeService.redisClient = rdb
-	return storeService
+	return NewStorageService(rdb)
+}
+
+// NewStorageService wraps an already configured Redis client
+func NewStorageService(rdb *redis.Client) *StorageService {
+	return &StorageService{redisClient: rdb}
+}
+
+const sequenceKey = "seq:codes"
+
+// workspacePrefix starts the keys of every workspace but the default one
+const workspacePrefix = "ws:"
+
+// hitScript increments the clicks counter of an existing link and keeps it alive exactly as long as the link
+var hitScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local clicks = redis.call('INCR', KEYS[2])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+else
+	redis.call('PERSIST', KEYS[2])
+end
+return clicks
+`)
+
+// scopedKey prefixes key with the workspace of ctx, the keys of the default workspace have no prefix
+func scopedKey(ctx context.Context, key string) string {
+	if workspace := WorkspaceFrom(ctx); workspace != DefaultWorkspace {
+		return workspacePrefix + workspace + ":" + key
+	}
+	return key
+}
+
+// linkKey holds the JSON encoded link
+func linkKey(ctx context.Context, code string) string {
+	return scopedKey(ctx, code)
+}
+
+func userKey(ctx context.Context, userId string) string {
+	return scopedKey(ctx, fmt.Sprintf("user:%s:links", userId))
+}
+
+func clicksKey(ctx context.Context, code string) string {
+	return scopedKey(ctx, fmt.Sprintf("clicks:%s", code))
+}
+
+func statsKey(ctx context.Context, code, day string) string {
+	return scopedKey(ctx, fmt.Sprintf("stats:%s:%s", code, day))
+}
+
+func visitorsKey(ctx context.Context, code, day string) string {
+	return scopedKey(ctx, fmt.Sprintf("visitors:%s:%s", code, day))
+}
+
+func clickLogKey(ctx context.Context, code, day string) string {
+	return scopedKey(ctx, fmt.Sprintf("clicklog:%s:%s", code, day))
+}
+
+// apiKeyKey holds a hash with the JSON encoded key in its data field and the last_used_at and revoked_at times apart,
+// so touching and revoking a key never overwrite each other. The keys are not scoped, their data names the workspace
+func apiKeyKey(id string) string {
+	return fmt.Sprintf("apikey:%s", id)
+}
+
+// statsDaysKey indexes the days with counters so Delete can find them
+func statsDaysKey(ctx context.Context, code string) string {
+	return scopedKey(ctx, fmt.Sprintf("stats:%s:days", code))
+}
+
+func (s *StorageService) Save(ctx context.Context, link *Link) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.redisClient.SetNX(ctx, linkKey(ctx, link.Code), data, retention(link, time.Now())).Result()
+	if err != nil {
+		return storageError(err)
+	}
+	if !ok {
+		return ErrConflict
+	}
+
+	err = s.redisClient.SAdd(ctx, userKey(ctx, link.UserId), link.Code).Err()
+	return storageError(err)
+}
+
+func (s *StorageService) Get(ctx context.Context, code string) (*Link, error) {
+	links, err := s.load(ctx, []string{code})
+	if err != nil {
+		return nil, err
+	}
+	if links[0] == nil {
+		return nil, ErrNotFound
+	}
+	return links[0], nil
+}
+
+func (s *StorageService) Update(ctx context.Context, link *Link) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	// the click counter follows the new retention of the link, a counter expiring first would lift its click limit
+	ttl := retention(link, time.Now())
+	pipe := s.redisClient.TxPipeline()
+	updated := pipe.SetXX(ctx, linkKey(ctx, link.Code), data, ttl)
+	if ttl > 0 {
+		pipe.PExpire(ctx, clicksKey(ctx, link.Code), ttl)
+	} else {
+		pipe.Persist(ctx, clicksKey(ctx, link.Code))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return storageError(err)
+	}
+	if !updated.Val() {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *StorageService) Delete(ctx context.Context, code string) error {
+	link, err := s.Get(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	days, err := s.redisClient.SMembers(ctx, statsDaysKey(ctx, code)).Result()
+	if err != nil {
+		return storageError(err)
+	}
+	keys := []string{linkKey(ctx, code), clicksKey(ctx, code), statsDaysKey(ctx, code)}
+	for _, day := range days {
+		keys = append(keys, statsKey(ctx, code, day), visitorsKey(ctx, code, day), clickLogKey(ctx, code, day))
+	}
+
+	pipe := s.redisClient.TxPipeline()
+	pipe.Del(ctx, keys...)
+	pipe.SRem(ctx, userKey(ctx, link.UserId), code)
+	_, err = pipe.Exec(ctx)
+	return storageError(err)
 }
 
-func SaveURLInRedis(shortURL, originalURL string) {
-	err := storeService.redisClient.Set(ctx, shortURL, originalURL, CacheDuration).Err()
+func (s *StorageService) List(ctx context.Context, userId string) ([]*Link, error) {
+	codes, err := s.redisClient.SMembers(ctx, userKey(ctx, userId)).Result()
 	if err != nil {
-		log.Printf("Failed SaveURLInRedis | Error: %v - shortURL: %s - originalURL: %s\n",
-			err, shortURL, originalURL)
+		return nil, storageError(err)
 	}
+	if len(codes) == 0 {
+		return []*Link{}, nil
+	}
+
+	loaded, err := s.load(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*Link, 0, len(loaded))
+	var evicted []interface{}
+	for i, link := range loaded {
+		if link == nil {
+			evicted = append(evicted, codes[i])
+			continue
+		}
+		links = append(links, link)
+	}
+
+	// links evicted by Redis are still members of the owner index
+	if len(evicted) > 0 {
+		if err := s.redisClient.SRem(ctx, userKey(ctx, userId), evicted...).Err(); err != nil {
+			return nil, storageError(err)
+		}
+	}
+	return links, nil
 }
 
-func RetrieveInitialURLFromRedis(shortURL string) string {
-	result, err := storeService.redisClient.Get(ctx, shortURL).Result()
+func (s *StorageService) Find(ctx context.Context, query LinkQuery) (*LinkPage, error) {
+	links, err := s.List(ctx, query.UserId)
 	if err != nil {
-		log.Printf("Failed RetrieveInitialURLFromRedis | Error: %v - shortURL: %s\n",
-			err, shortURL)
+		return nil, err
+	}
+	return paginate(links, query)
+}
+
+func (s *StorageService) NextSequence(ctx context.Context) (uint64, error) {
+	value, err := s.redisClient.Incr(ctx, scopedKey(ctx, sequenceKey)).Uint64()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return value, nil
+}
+
+func (s *StorageService) Hit(ctx context.Context, link *Link) (int64, error) {
+	clicks, err := hitScript.Run(ctx, s.redisClient, []string{linkKey(ctx, link.Code), clicksKey(ctx, link.Code)}).Int64()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	if clicks < 0 {
+		return 0, ErrNotFound
+	}
+	// the counter keeps growing past the limit, every concurrent hit gets its own value so only MaxClicks succeed
+	if link.MaxClicks > 0 && clicks > link.MaxClicks {
+		return link.MaxClicks, ErrExhausted
+	}
+	return clicks, nil
+}
+
+func (s *StorageService) IncrementCounters(ctx context.Context, code string, day time.Time, deltas map[string]int64) error {
+	key := statsKey(ctx, code, day.UTC().Format(DayLayout))
+	pipe := s.redisClient.TxPipeline()
+	for field, delta := range deltas {
+		pipe.HIncrBy(ctx, key, field, delta)
+	}
+	pipe.Expire(ctx, key, StatsRetention)
+	pipe.SAdd(ctx, statsDaysKey(ctx, code), day.UTC().Format(DayLayout))
+	pipe.Expire(ctx, statsDaysKey(ctx, code), StatsRetention)
+	_, err := pipe.Exec(ctx)
+	return storageError(err)
+}
+
+func (s *StorageService) Counters(ctx context.Context, code string, from, to time.Time) ([]DailyCounters, error) {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.redisClient.Pipeline()
+	results := make([]*redis.MapStringStringCmd, len(days))
+	for i, day := range days {
+		results[i] = pipe.HGetAll(ctx, statsKey(ctx, code, day))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, storageError(err)
+	}
+
+	counters := []DailyCounters{}
+	for i, result := range results {
+		if len(result.Val()) == 0 {
+			continue
+		}
+		values := make(map[string]int64, len(result.Val()))
+		for field, value := range result.Val() {
+			values[field], _ = strconv.ParseInt(value, 10, 64)
+		}
+		counters = append(counters, DailyCounters{Day: days[i], Counters: values})
+	}
+	return counters, nil
+}
+
+func (s *StorageService) AddVisitors(ctx context.Context, code string, day time.Time, visitors []string) error {
+	if len(visitors) == 0 {
+		return nil
+	}
+	key := visitorsKey(ctx, code, day.UTC().Format(DayLayout))
+	members := make([]interface{}, len(visitors))
+	for i, visitor := range visitors {
+		members[i] = visitor
+	}
+
+	pipe := s.redisClient.TxPipeline()
+	pipe.PFAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, StatsRetention)
+	pipe.SAdd(ctx, statsDaysKey(ctx, code), day.UTC().Format(DayLayout))
+	pipe.Expire(ctx, statsDaysKey(ctx, code), StatsRetention)
+	_, err := pipe.Exec(ctx)
+	return storageError(err)
+}
+
+func (s *StorageService) CountVisitors(ctx context.Context, code string, from, to time.Time) (int64, error) {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return 0, err
+	}
+	keys := make([]string, len(days))
+	for i, day := range days {
+		keys[i] = visitorsKey(ctx, code, day)
+	}
+
+	// PFCOUNT estimates the cardinality of the union of the daily sketches
+	visitors, err := s.redisClient.PFCount(ctx, keys...).Result()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return visitors, nil
+}
+
+func (s *StorageService) AppendClicks(ctx context.Context, clicks []ClickRecord) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	pipe := s.redisClient.TxPipeline()
+	for _, click := range clicks {
+		data, err := json.Marshal(click)
+		if err != nil {
+			return err
+		}
+		day := click.Time.UTC().Format(DayLayout)
+		pipe.RPush(ctx, clickLogKey(ctx, click.Code, day), data)
+		pipe.Expire(ctx, clickLogKey(ctx, click.Code, day), StatsRetention)
+		pipe.SAdd(ctx, statsDaysKey(ctx, click.Code), day)
+		pipe.Expire(ctx, statsDaysKey(ctx, click.Code), StatsRetention)
+	}
+	_, err := pipe.Exec(ctx)
+	return storageError(err)
+}
+
+func (s *StorageService) ScanClicks(ctx context.Context, code string, from, to time.Time, fn func(ClickRecord) error) error {
+	days, err := counterDays(from, to)
+	if err != nil {
+		return err
+	}
+
+	for _, day := range days {
+		for start := int64(0); ; start += clickLogPageSize {
+			values, err := s.redisClient.LRange(ctx, clickLogKey(ctx, code, day), start, start+clickLogPageSize-1).Result()
+			if err != nil {
+				return storageError(err)
+			}
+			for _, value := range values {
+				var click ClickRecord
+				if err := json.Unmarshal([]byte(value), &click); err != nil {
+					return err
+				}
+				if err := fn(click); err != nil {
+					return err
+				}
+			}
+			if len(values) < clickLogPageSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (s *StorageService) SaveKey(ctx context.Context, key *APIKey) error {
+	stored := *key
+	stored.LastUsedAt, stored.RevokedAt = nil, nil
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	saved, err := s.redisClient.HSetNX(ctx, apiKeyKey(key.Id), "data", data).Result()
+	if err != nil {
+		return storageError(err)
+	}
+	if !saved {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *StorageService) GetKey(ctx context.Context, id string) (*APIKey, error) {
+	fields, err := s.redisClient.HGetAll(ctx, apiKeyKey(id)).Result()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if fields["data"] == "" {
+		return nil, ErrNotFound
+	}
+
+	var key APIKey
+	if err := json.Unmarshal([]byte(fields["data"]), &key); err != nil {
+		return nil, err
+	}
+	if at, err := time.Parse(time.RFC3339Nano, fields["last_used_at"]); err == nil {
+		key.LastUsedAt = &at
+	}
+	if at, err := time.Parse(time.RFC3339Nano, fields["revoked_at"]); err == nil {
+		key.RevokedAt = &at
+	}
+	return &key, nil
+}
+
+func (s *StorageService) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	if err := s.keyExists(ctx, id); err != nil {
+		return err
+	}
+	return storageError(s.redisClient.HSetNX(ctx, apiKeyKey(id), "revoked_at", at.UTC().Format(time.RFC3339Nano)).Err())
+}
+
+func (s *StorageService) TouchKey(ctx context.Context, id string, at time.Time) error {
+	if err := s.keyExists(ctx, id); err != nil {
+		return err
+	}
+	return storageError(s.redisClient.HSet(ctx, apiKeyKey(id), "last_used_at", at.UTC().Format(time.RFC3339Nano)).Err())
+}
+
+func (s *StorageService) keyExists(ctx context.Context, id string) error {
+	exists, err := s.redisClient.HExists(ctx, apiKeyKey(id), "data").Result()
+	if err != nil {
+		return storageError(err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// load reads the links and their clicks counters, missing links are returned as nil
+func (s *StorageService) load(ctx context.Context, codes []string) ([]*Link, error) {
+	keys := make([]string, 0, 2*len(codes))
+	for _, code := range codes {
+		keys = append(keys, linkKey(ctx, code))
+	}
+	for _, code := range codes {
+		keys = append(keys, clicksKey(ctx, code))
+	}
+
+	values, err := s.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	links := make([]*Link, len(codes))
+	for i, code := range codes {
+		raw, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		link := decodeLink(code, raw)
+		link.Clicks = 0
+		if counter, ok := values[len(codes)+i].(string); ok {
+			link.Clicks, _ = strconv.ParseInt(counter, 10, 64)
+		}
+		if link.MaxClicks > 0 {
+			link.Clicks = min(link.Clicks, link.MaxClicks)
+		}
+		links[i] = link
+	}
+	return links, nil
+}
+
+// decodeLink accepts both the JSON document and the bare URL stored by previous versions
+func decodeLink(code, raw string) *Link {
+	var link Link
+	if err := json.Unmarshal([]byte(raw), &link); err != nil {
+		return &Link{Code: code, LongURL: raw}
 	}
-	return result
+	return &link
 }
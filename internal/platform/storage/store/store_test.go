@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorageService(t *testing.T) (*StorageService, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	return NewStorageService(redis.NewClient(&redis.Options{Addr: mr.Addr()})), mr
+}
+
+func TestStorageServiceSaveAndGet(t *testing.T) {
+	s, mr := newTestStorageService(t)
+	ctx := context.Background()
+
+	link := &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1", CreatedAt: time.Now().UTC()}
+	require.NoError(t, s.Save(ctx, link))
+
+	got, err := s.Get(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, link.LongURL, got.LongURL)
+	assert.Equal(t, link.UserId, got.UserId)
+	assert.Zero(t, mr.TTL("abc123"))
+}
+
+func TestStorageServiceKeepsExpiredLinksForRetention(t *testing.T) {
+	s, mr := newTestStorageService(t)
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, s.Save(ctx, &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1", ExpiresAt: &expiresAt}))
+	assert.InDelta(t, (time.Hour + ExpiredRetention).Seconds(), mr.TTL("abc123").Seconds(), 5)
+
+	got, err := s.Get(ctx, "abc123")
+	require.NoError(t, err)
+	require.NotNil(t, got.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*got.ExpiresAt))
+
+	mr.FastForward(time.Hour + ExpiredRetention)
+	links, err := s.List(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, links)
+	members, err := mr.Members(userKey(ctx, "1"))
+	assert.Error(t, err)
+	assert.Empty(t, members)
+}
+
+func TestStorageServiceGetReturnsNotFound(t *testing.T) {
+	s, _ := newTestStorageService(t)
+
+	_, err := s.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStorageServiceGetReadsLegacyValues(t *testing.T) {
+	s, mr := newTestStorageService(t)
+	require.NoError(t, mr.Set("legacy", "https://example.com/legacy"))
+
+	got, err := s.Get(context.Background(), "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/legacy", got.LongURL)
+}
+
+func TestStorageServiceUpdate(t *testing.T) {
+	s, _ := newTestStorageService(t)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, s.Update(ctx, &Link{Code: "missing"}), ErrNotFound)
+
+	require.NoError(t, s.Save(ctx, &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}))
+	require.NoError(t, s.Update(ctx, &Link{Code: "abc123", LongURL: "https://example.org", UserId: "1"}))
+
+	got, err := s.Get(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org", got.LongURL)
+}
+
+func TestStorageServiceUpdateMovesTheClickCounterToTheNewRetention(t *testing.T) {
+	s, mr := newTestStorageService(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	link := &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1", ExpiresAt: &expiresAt, MaxClicks: 2}
+	require.NoError(t, s.Save(ctx, link))
+	_, err := s.Hit(ctx, link)
+	require.NoError(t, err)
+
+	extended := time.Now().Add(90 * 24 * time.Hour)
+	link.ExpiresAt = &extended
+	require.NoError(t, s.Update(ctx, link))
+	assert.Equal(t, mr.TTL(linkKey(ctx, "abc123")), mr.TTL(clicksKey(ctx, "abc123")))
+	assert.Greater(t, mr.TTL(clicksKey(ctx, "abc123")), 90*24*time.Hour)
+
+	link.ExpiresAt = nil
+	require.NoError(t, s.Update(ctx, link))
+	assert.Zero(t, mr.TTL(linkKey(ctx, "abc123")))
+	assert.Zero(t, mr.TTL(clicksKey(ctx, "abc123")))
+
+	// the counter outlives the fast forward, so the limit still holds
+	mr.FastForward(365 * 24 * time.Hour)
+	_, err = s.Hit(ctx, link)
+	require.NoError(t, err)
+	_, err = s.Hit(ctx, link)
+	assert.ErrorIs(t, err, ErrExhausted)
+}
+
+func TestStorageServiceDeleteAndList(t *testing.T) {
+	s, _ := newTestStorageService(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, &Link{Code: "first", LongURL: "https://example.com/1", UserId: "1"}))
+	require.NoError(t, s.Save(ctx, &Link{Code: "second", LongURL: "https://example.com/2", UserId: "1"}))
+	require.NoError(t, s.Save(ctx, &Link{Code: "other", LongURL: "https://example.com/3", UserId: "2"}))
+
+	links, err := s.List(ctx, "1")
+	require.NoError(t, err)
+	assert.Len(t, links, 2)
+
+	require.NoError(t, s.Delete(ctx, "first"))
+	assert.ErrorIs(t, s.Delete(ctx, "first"), ErrNotFound)
+
+	links, err = s.List(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "second", links[0].Code)
+}
+
+func TestStorageServiceReturnsUnavailableWhenRedisIsDown(t *testing.T) {
+	s, mr := newTestStorageService(t)
+	mr.Close()
+	ctx := context.Background()
+
+	assert.ErrorIs(t, s.Save(ctx, &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1"}), ErrUnavailable)
+
+	_, err := s.Get(ctx, "abc123")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
+
+func TestStorageServiceSaveReturnsConflictWhenCodeIsTaken(t *testing.T) {
+	s, _ := newTestStorageService(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, &Link{Code: "taken", LongURL: "https://example.com/1", UserId: "1"}))
+	assert.ErrorIs(t, s.Save(ctx, &Link{Code: "taken", LongURL: "https://example.com/2", UserId: "2"}), ErrConflict)
+
+	got, err := s.Get(ctx, "taken")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", got.LongURL)
+}
+
+func TestStorageServiceNextSequenceIncrements(t *testing.T) {
+	s, _ := newTestStorageService(t)
+	ctx := context.Background()
+
+	first, err := s.NextSequence(ctx)
+	require.NoError(t, err)
+	second, err := s.NextSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, first+1, second)
+}
+
+func TestStorageServiceHitEnforcesMaxClicks(t *testing.T) {
+	s, mr := newTestStorageService(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	link := &Link{Code: "twice", LongURL: "https://example.com", UserId: "1", MaxClicks: 2, ExpiresAt: &expiresAt}
+	require.NoError(t, s.Save(ctx, link))
+
+	for want := int64(1); want <= 2; want++ {
+		clicks, err := s.Hit(ctx, link)
+		require.NoError(t, err)
+		assert.Equal(t, want, clicks)
+	}
+	_, err := s.Hit(ctx, link)
+	assert.ErrorIs(t, err, ErrExhausted)
+
+	got, err := s.Get(ctx, "twice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), got.Clicks)
+	// the counter lives as long as the link
+	assert.Equal(t, mr.TTL("twice"), mr.TTL(clicksKey(ctx, "twice")))
+
+	require.NoError(t, s.Delete(ctx, "twice"))
+	assert.False(t, mr.Exists(clicksKey(ctx, "twice")))
+	_, err = s.Hit(ctx, link)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStorageServiceFind(t *testing.T) {
+	s, _ := newTestStorageService(t)
+	assertFindPagesAndFilters(t, s)
+}
+
+func TestStorageServiceClickAnalytics(t *testing.T) {
+	s, mr := newTestStorageService(t)
+	assertClickAnalytics(t, s)
+	ctx := context.Background()
+	// only the log of the other link is left
+	assert.ElementsMatch(t, []string{clickLogKey(ctx, "other", "2025-03-10"), statsDaysKey(ctx, "other")}, mr.Keys())
+	assert.Equal(t, StatsRetention, mr.TTL(clickLogKey(ctx, "other", "2025-03-10")))
+}
+
+func TestStorageServiceCountsVisitorsWithHyperLogLog(t *testing.T) {
+	s, mr := newTestStorageService(t)
+	ctx := context.Background()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, s.AddVisitors(ctx, "abc123", day, []string{"a", "b", "a"}))
+	require.NoError(t, s.AddVisitors(ctx, "abc123", day.AddDate(0, 0, 1), []string{"c"}))
+
+	// miniredis adds up the counts of the keys given to PFCOUNT where Redis counts their union, so the days of this
+	// range do not share visitors
+	visitors, err := s.CountVisitors(ctx, "abc123", day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), visitors)
+	assert.Equal(t, StatsRetention, mr.TTL(visitorsKey(ctx, "abc123", "2025-03-10")))
+}
+
+func TestStorageServiceAPIKeys(t *testing.T) {
+	s, _ := newTestStorageService(t)
+	assertAPIKeys(t, s)
+}
+
+func TestStorageServiceWorkspaces(t *testing.T) {
+	s, mr := newTestStorageService(t)
+	assertWorkspaces(t, s)
+	// the keys of a workspace are prefixed with its name, the default workspace keeps the unprefixed keys
+	assert.True(t, mr.Exists("abc123"))
+	assert.True(t, mr.Exists("ws:eng:"+sequenceKey))
+	assert.False(t, mr.Exists("ws:eng:abc123"))
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// DefaultWorkspace holds the data of the requests that name no workspace, it keeps the layout of the single tenant
+// versions so the links created before workspaces existed keep working
+const DefaultWorkspace = ""
+
+// workspacePattern are the workspace names, they are part of the short URLs and of the storage keys
+var workspacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,31}$`)
+
+type workspaceKey struct{}
+
+// WithWorkspace returns a context whose storage operations read and write the data of workspace. Every backend
+// partitions the links, their stats and click logs by workspace: the same code can exist in several workspaces
+func WithWorkspace(ctx context.Context, workspace string) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspace)
+}
+
+// WorkspaceFrom returns the workspace of ctx, DefaultWorkspace when none was set
+func WorkspaceFrom(ctx context.Context) string {
+	workspace, _ := ctx.Value(workspaceKey{}).(string)
+	return workspace
+}
+
+// ValidWorkspace reports whether name can name a workspace: 2 to 32 lowercase letters, digits or '-'
+func ValidWorkspace(name string) bool {
+	return workspacePattern.MatchString(name)
+}
+
+// scopedId namespaces id in workspace for the backends that keep every workspace in the same maps or buckets. The ids
+// of the default workspace are unchanged, the others start with a NUL byte that no code or user id contains
+func scopedId(workspace, id string) string {
+	if workspace == DefaultWorkspace {
+		return id
+	}
+	return "\x00" + workspace + "\x00" + id
+}
+
+// splitScopedId returns the workspace and the id namespaced by scopedId
+func splitScopedId(scoped string) (string, string) {
+	rest, ok := strings.CutPrefix(scoped, "\x00")
+	if !ok {
+		return DefaultWorkspace, scoped
+	}
+	workspace, id, _ := strings.Cut(rest, "\x00")
+	return workspace, id
+}
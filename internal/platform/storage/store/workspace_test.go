@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertWorkspaces runs the same isolation scenarios between workspaces against any backend
+func assertWorkspaces(t *testing.T, repo Repository) {
+	base := context.Background()
+	eng := WithWorkspace(base, "eng")
+	day := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	require.NoError(t, repo.Save(base, &Link{Code: "abc123", LongURL: "https://example.com", UserId: "1", CreatedAt: day}))
+	// the same code and user exist in another workspace without conflict
+	require.NoError(t, repo.Save(eng, &Link{Code: "abc123", LongURL: "https://eng.example.com", UserId: "1",
+		CreatedAt: day, MaxClicks: 1}))
+	assert.ErrorIs(t, repo.Save(eng, &Link{Code: "abc123", LongURL: "https://other.example.com", UserId: "2",
+		CreatedAt: day}), ErrConflict)
+
+	link, err := repo.Get(base, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", link.LongURL)
+	link, err = repo.Get(eng, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://eng.example.com", link.LongURL)
+	_, err = repo.Get(WithWorkspace(base, "sales"), "abc123")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	links, err := repo.List(eng, "1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "https://eng.example.com", links[0].LongURL)
+	page, err := repo.Find(WithWorkspace(base, "sales"), LinkQuery{UserId: "1", Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Links)
+
+	// the click limit of the link of eng does not apply to the link of the default workspace
+	clicks, err := repo.Hit(eng, link)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), clicks)
+	_, err = repo.Hit(eng, link)
+	assert.ErrorIs(t, err, ErrExhausted)
+	clicks, err = repo.Hit(base, &Link{Code: "abc123"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), clicks)
+
+	require.NoError(t, repo.IncrementCounters(eng, "abc123", day, map[string]int64{"total": 2}))
+	require.NoError(t, repo.AddVisitors(eng, "abc123", day, []string{"a"}))
+	require.NoError(t, repo.AppendClicks(eng, []ClickRecord{{Code: "abc123", Time: day, Data: json.RawMessage(`{}`)}}))
+	counters, err := repo.Counters(base, "abc123", day, day)
+	require.NoError(t, err)
+	assert.Empty(t, counters)
+	visitors, err := repo.CountVisitors(base, "abc123", day, day)
+	require.NoError(t, err)
+	assert.Zero(t, visitors)
+	scanned := 0
+	require.NoError(t, repo.ScanClicks(base, "abc123", day, day, func(ClickRecord) error {
+		scanned++
+		return nil
+	}))
+	assert.Zero(t, scanned)
+
+	// every workspace counts its own sequence
+	first, err := repo.NextSequence(base)
+	require.NoError(t, err)
+	second, err := repo.NextSequence(base)
+	require.NoError(t, err)
+	other, err := repo.NextSequence(eng)
+	require.NoError(t, err)
+	assert.Equal(t, first+1, second)
+	assert.Equal(t, uint64(1), other)
+
+	require.NoError(t, repo.Delete(eng, "abc123"))
+	_, err = repo.Get(eng, "abc123")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = repo.Get(base, "abc123")
+	assert.NoError(t, err)
+	counters, err = repo.Counters(eng, "abc123", day, day)
+	require.NoError(t, err)
+	assert.Empty(t, counters)
+}
+
+func TestValidWorkspace(t *testing.T) {
+	for _, name := range []string{"eng", "sales-emea", "a1"} {
+		assert.True(t, ValidWorkspace(name), name)
+	}
+	for _, name := range []string{"", "a", "Eng", "-eng", "eng/ops", "eng ops", "a23456789012345678901234567890123"} {
+		assert.False(t, ValidWorkspace(name), name)
+	}
+}
+
+func TestMemoryStoreWorkspaces(t *testing.T) {
+	assertWorkspaces(t, NewMemoryStore())
+}
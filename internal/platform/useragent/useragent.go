@@ -0,0 +1,187 @@
+// Package useragent classifies the clients following the short links from their User-Agent header
+package useragent
+
+import (
+	"strings"
+)
+
+const (
+	ClassHuman = "human"
+	// ClassCrawler is a search engine or AI crawler indexing the destination
+	ClassCrawler = "crawler"
+	// ClassUnfurler fetches the link to render a preview in a chat or a social network
+	ClassUnfurler = "unfurler"
+	// ClassMonitor checks that the link is up
+	ClassMonitor = "monitor"
+	// ClassBot is any other automated client, such as curl or an HTTP library
+	ClassBot = "bot"
+
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+
+	Other = "Other"
+)
+
+// Agent is what the User-Agent header tells about a client
+type Agent struct {
+	Browser string
+	OS      string
+	Device  string
+	Class   string
+	// Bot names the automated client, it is empty for humans
+	Bot string
+}
+
+// IsBot reports whether the client is automated
+func (a Agent) IsBot() bool {
+	return a.Class != ClassHuman
+}
+
+type botRule struct {
+	token string
+	name  string
+	class string
+}
+
+// knownBots are matched case-insensitively in order, the first matching token wins
+var knownBots = []botRule{
+	{"slackbot", "Slack", ClassUnfurler},
+	{"slack-imgproxy", "Slack", ClassUnfurler},
+	{"microsoft teams", "Microsoft Teams", ClassUnfurler},
+	{"skypeuripreview", "Microsoft Teams", ClassUnfurler},
+	{"facebookexternalhit", "Facebook", ClassUnfurler},
+	{"facebot", "Facebook", ClassUnfurler},
+	{"twitterbot", "Twitter", ClassUnfurler},
+	{"linkedinbot", "LinkedIn", ClassUnfurler},
+	{"whatsapp", "WhatsApp", ClassUnfurler},
+	{"telegrambot", "Telegram", ClassUnfurler},
+	{"discordbot", "Discord", ClassUnfurler},
+	{"redditbot", "Reddit", ClassUnfurler},
+	{"mastodon", "Mastodon", ClassUnfurler},
+	{"embedly", "Embedly", ClassUnfurler},
+	{"iframely", "Iframely", ClassUnfurler},
+	{"googlebot", "Googlebot", ClassCrawler},
+	{"bingbot", "Bingbot", ClassCrawler},
+	{"duckduckbot", "DuckDuckBot", ClassCrawler},
+	{"baiduspider", "Baiduspider", ClassCrawler},
+	{"yandexbot", "YandexBot", ClassCrawler},
+	{"applebot", "Applebot", ClassCrawler},
+	{"ahrefsbot", "AhrefsBot", ClassCrawler},
+	{"semrushbot", "SemrushBot", ClassCrawler},
+	{"petalbot", "PetalBot", ClassCrawler},
+	{"gptbot", "GPTBot", ClassCrawler},
+	{"claudebot", "ClaudeBot", ClassCrawler},
+	{"ccbot", "CCBot", ClassCrawler},
+	{"bytespider", "Bytespider", ClassCrawler},
+	{"amazonbot", "Amazonbot", ClassCrawler},
+	{"uptimerobot", "UptimeRobot", ClassMonitor},
+	{"pingdom", "Pingdom", ClassMonitor},
+	{"statuscake", "StatusCake", ClassMonitor},
+	{"site24x7", "Site24x7", ClassMonitor},
+	{"datadog", "Datadog", ClassMonitor},
+	{"newrelicpinger", "New Relic", ClassMonitor},
+	{"better uptime", "Better Uptime", ClassMonitor},
+	{"checkly", "Checkly", ClassMonitor},
+	{"kube-probe", "Kubernetes", ClassMonitor},
+	{"elb-healthchecker", "AWS ELB", ClassMonitor},
+	{"googlehc", "Google Cloud", ClassMonitor},
+	{"curl/", "curl", ClassBot},
+	{"wget/", "Wget", ClassBot},
+	{"python-requests", "Python", ClassBot},
+	{"python-urllib", "Python", ClassBot},
+	{"go-http-client", "Go", ClassBot},
+	{"okhttp", "OkHttp", ClassBot},
+	{"headlesschrome", "Headless Chrome", ClassBot},
+}
+
+// genericBotTokens mark unknown automated clients
+var genericBotTokens = []string{"bot", "crawler", "spider", "scraper", "preview", "fetcher", "monitor"}
+
+// Parse classifies the client from its User-Agent header, an empty header is a bot
+func Parse(userAgent string) Agent {
+	agent := Agent{
+		Browser: browser(userAgent),
+		OS:      operatingSystem(userAgent),
+		Class:   ClassHuman,
+	}
+
+	lower := strings.ToLower(userAgent)
+	for _, rule := range knownBots {
+		if strings.Contains(lower, rule.token) {
+			agent.Class, agent.Bot = rule.class, rule.name
+			break
+		}
+	}
+	if agent.Class == ClassHuman {
+		for _, token := range genericBotTokens {
+			if strings.Contains(lower, token) {
+				agent.Class, agent.Bot = ClassBot, Other
+				break
+			}
+		}
+	}
+	if strings.TrimSpace(userAgent) == "" {
+		agent.Class, agent.Bot = ClassBot, Other
+	}
+
+	agent.Device = device(userAgent, agent)
+	return agent
+}
+
+func browser(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "Edg/") || strings.Contains(userAgent, "EdgiOS") ||
+		strings.Contains(userAgent, "EdgA/"):
+		return "Edge"
+	case strings.Contains(userAgent, "OPR/") || strings.Contains(userAgent, "Opera"):
+		return "Opera"
+	case strings.Contains(userAgent, "SamsungBrowser"):
+		return "Samsung Internet"
+	case strings.Contains(userAgent, "Firefox/") || strings.Contains(userAgent, "FxiOS"):
+		return "Firefox"
+	case strings.Contains(userAgent, "Chrome/") || strings.Contains(userAgent, "CriOS"):
+		return "Chrome"
+	case strings.Contains(userAgent, "Safari/") && strings.Contains(userAgent, "Version/"):
+		return "Safari"
+	case strings.Contains(userAgent, "MSIE") || strings.Contains(userAgent, "Trident/"):
+		return "Internet Explorer"
+	default:
+		return Other
+	}
+}
+
+func operatingSystem(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "Windows"):
+		return "Windows"
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPad") ||
+		strings.Contains(userAgent, "iPod"):
+		return "iOS"
+	case strings.Contains(userAgent, "Android"):
+		return "Android"
+	case strings.Contains(userAgent, "CrOS"):
+		return "ChromeOS"
+	case strings.Contains(userAgent, "Macintosh") || strings.Contains(userAgent, "Mac OS X"):
+		return "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		return "Linux"
+	default:
+		return Other
+	}
+}
+
+func device(userAgent string, agent Agent) string {
+	switch {
+	case agent.IsBot():
+		return DeviceBot
+	case strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet") ||
+		agent.OS == "Android" && !strings.Contains(userAgent, "Mobile"):
+		return DeviceTablet
+	case strings.Contains(userAgent, "Mobi") || strings.Contains(userAgent, "iPhone") || agent.OS == "Android":
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
@@ -0,0 +1,82 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBrowsers(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      Agent
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			Agent{Browser: "Chrome", OS: "Windows", Device: DeviceDesktop, Class: ClassHuman},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			Agent{Browser: "Edge", OS: "Windows", Device: DeviceDesktop, Class: ClassHuman},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			Agent{Browser: "Safari", OS: "macOS", Device: DeviceDesktop, Class: ClassHuman},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			Agent{Browser: "Safari", OS: "iOS", Device: DeviceMobile, Class: ClassHuman},
+		},
+		{
+			"Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.88 Mobile/15E148 Safari/604.1",
+			Agent{Browser: "Chrome", OS: "iOS", Device: DeviceTablet, Class: ClassHuman},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			Agent{Browser: "Chrome", OS: "Android", Device: DeviceMobile, Class: ClassHuman},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Safari/537.36",
+			Agent{Browser: "Samsung Internet", OS: "Android", Device: DeviceTablet, Class: ClassHuman},
+		},
+		{
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			Agent{Browser: "Firefox", OS: "Linux", Device: DeviceDesktop, Class: ClassHuman},
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Parse(tt.userAgent), tt.userAgent)
+	}
+}
+
+func TestParseBots(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		class     string
+		bot       string
+	}{
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", ClassUnfurler, "Slack"},
+		{"Mozilla/5.0 (Windows NT 6.1; WOW64) SkypeUriPreview Preview/0.5 skype-url-preview@microsoft.com", ClassUnfurler, "Microsoft Teams"},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", ClassUnfurler, "Facebook"},
+		{"Twitterbot/1.0", ClassUnfurler, "Twitter"},
+		{"LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)", ClassUnfurler, "LinkedIn"},
+		{"WhatsApp/2.23.20.0", ClassUnfurler, "WhatsApp"},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", ClassCrawler, "Googlebot"},
+		{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", ClassCrawler, "Bingbot"},
+		{"Mozilla/5.0 (compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)", ClassMonitor, "UptimeRobot"},
+		{"kube-probe/1.29", ClassMonitor, "Kubernetes"},
+		{"curl/8.4.0", ClassBot, "curl"},
+		{"python-requests/2.31.0", ClassBot, "Python"},
+		{"SomeNewCrawler/0.1", ClassBot, Other},
+		{"", ClassBot, Other},
+	}
+
+	for _, tt := range tests {
+		agent := Parse(tt.userAgent)
+		assert.Equal(t, tt.class, agent.Class, tt.userAgent)
+		assert.Equal(t, tt.bot, agent.Bot, tt.userAgent)
+		assert.Equal(t, DeviceBot, agent.Device, tt.userAgent)
+		assert.True(t, agent.IsBot(), tt.userAgent)
+	}
+}